@@ -13,11 +13,14 @@ func main() {
 	//dirs := strings.SplitAfter(currentDirectory, "aws-auto-scaling-listener")
 	//appPath := path.Join(dirs[0], "common", "config", "env")
 	//
-	//conf, err := config.NewConfig(appPath, "env.yaml")
+	//logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	//
+	//conf, err := config.NewConfig(appPath, "env.yaml", config.WithSecretResolver(func(a config.AWS) config.SecretResolver {
+	//	return aws.NewSecretResolver(ctx, *logger, a.AWSAccessKey, a.AWSSecretAccessKey, a.Session)
+	//}))
 	//if err != nil {
 	//	log.Fatal(err)
 	//}
-	//logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	//
 	//awsCli := aws.NewAWSClient(ctx, *logger, conf)
 	//in, err := awsCli.EC2.GetInstanceByID("i-1234124124")
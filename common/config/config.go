@@ -10,6 +10,7 @@ import (
 type Config struct {
 	AppPath string `yaml:"-"`
 	AWS     AWS    `yaml:"aws"`
+	Docker  Docker `yaml:"docker"`
 }
 
 type AWS struct {
@@ -18,8 +19,42 @@ type AWS struct {
 	Session            string `yaml:"session"` //optional?
 }
 
-func NewConfig(appPath, envFile string) (Config, error) {
+type Docker struct {
+	Registries []Registry `yaml:"registries"`
+}
+
+// Registry holds credentials for a single docker registry, Server is the registry host e.g. ghcr.io
+type Registry struct {
+	Server   string `yaml:"server"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// Option configures how NewConfig loads the config
+type Option func(*options)
+
+type options struct {
+	newResolver func(AWS) SecretResolver
+}
+
+// WithSecretResolver resolves secret references while the config is loaded
+// newResolver is called with the loaded AWS section so the resolver can use the same credentials
+func WithSecretResolver(newResolver func(AWS) SecretResolver) Option {
+	return func(o *options) {
+		o.newResolver = newResolver
+	}
+}
+
+// NewConfig reads envFile from appPath, secret references are resolved with the WithSecretResolver option
+// Loading a config that references secrets without a resolver fails instead of using the references as values
+// AWS credentials are read from the aws section (aws.aws_access_key, aws.aws_secret_access_key and aws.session), top level keys are ignored
+func NewConfig(appPath, envFile string, opts ...Option) (Config, error) {
 	var c Config
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	if _, err := os.Stat(filepath.Join(appPath, envFile)); err != nil {
 		return c, err
 	}
@@ -33,9 +68,21 @@ func NewConfig(appPath, envFile string) (Config, error) {
 	}
 
 	c.AppPath = appPath
-	c.AWS.AWSAccessKey = vp.GetString("aws_access_key")
-	c.AWS.AWSSecretAccessKey = vp.GetString("aws_secret_access_key")
-	c.AWS.Session = vp.GetString("session")
+	c.AWS.AWSAccessKey = vp.GetString("aws.aws_access_key")
+	c.AWS.AWSSecretAccessKey = vp.GetString("aws.aws_secret_access_key")
+	c.AWS.Session = vp.GetString("aws.session")
+	if err := vp.UnmarshalKey("docker.registries", &c.Docker.Registries); err != nil {
+		return c, err
+	}
+
+	if o.newResolver != nil {
+		if err := c.ResolveSecrets(o.newResolver(c.AWS)); err != nil {
+			return c, err
+		}
+	}
+	if err := c.CheckResolved(); err != nil {
+		return c, err
+	}
 
 	return c, nil
 }
@@ -0,0 +1,105 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/onurcevik/deploy-utilities/common/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResolver resolves references from a map and records every reference it was asked for
+type fakeResolver struct {
+	values    map[string]string
+	requested []string
+}
+
+func (r *fakeResolver) ResolveSecret(ref string) (string, error) {
+	r.requested = append(r.requested, ref)
+	value, ok := r.values[ref]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return value, nil
+}
+
+func registriesConfig() config.Config {
+	return config.Config{Docker: config.Docker{Registries: []config.Registry{
+		{Server: "ghcr.io", Username: "deployer", Password: "ssm:/deploy/ghcr/password"},
+		{Server: "registry.example.com", Username: "secretsmanager:deploy/registry#username", Password: "plain-password"},
+	}}}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	conf := registriesConfig()
+	resolver := &fakeResolver{values: map[string]string{
+		"ssm:/deploy/ghcr/password":               "ghcr-token",
+		"secretsmanager:deploy/registry#username": "robot",
+	}}
+
+	require.NoError(t, conf.ResolveSecrets(resolver))
+	assert.Equal(t, "ghcr-token", conf.Docker.Registries[0].Password)
+	assert.Equal(t, "robot", conf.Docker.Registries[1].Username)
+	require.NoError(t, conf.CheckResolved())
+
+	// Plain values are kept as they are and never sent to the resolver
+	assert.Equal(t, "deployer", conf.Docker.Registries[0].Username)
+	assert.Equal(t, "plain-password", conf.Docker.Registries[1].Password)
+	assert.ElementsMatch(t, []string{"ssm:/deploy/ghcr/password", "secretsmanager:deploy/registry#username"}, resolver.requested)
+}
+
+func TestResolveSecretsError(t *testing.T) {
+	conf := registriesConfig()
+
+	err := conf.ResolveSecrets(&fakeResolver{})
+	assert.ErrorContains(t, err, "could not resolve docker.registries")
+}
+
+func TestCheckResolved(t *testing.T) {
+	conf := registriesConfig()
+
+	err := conf.CheckResolved()
+	assert.EqualError(t, err, "unresolved secret references in docker.registries[0].password, docker.registries[1].username")
+
+	plain := config.Config{Docker: config.Docker{Registries: []config.Registry{
+		{Server: "ghcr.io", Username: "deployer", Password: "plain-password"},
+	}}}
+	assert.NoError(t, plain.CheckResolved())
+}
+
+const envFile = `aws:
+  aws_access_key: "key"
+  aws_secret_access_key: "secret"
+  session: "token"
+docker:
+  registries:
+    - server: "ghcr.io"
+      username: "deployer"
+      password: "ssm:/deploy/ghcr/password"
+`
+
+func TestNewConfigWithSecretResolver(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "env.yaml"), []byte(envFile), 0644))
+
+	var resolverAWS config.AWS
+	conf, err := config.NewConfig(dir, "env.yaml", config.WithSecretResolver(func(a config.AWS) config.SecretResolver {
+		resolverAWS = a
+		return &fakeResolver{values: map[string]string{"ssm:/deploy/ghcr/password": "ghcr-token"}}
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "ghcr-token", conf.Docker.Registries[0].Password)
+	assert.Equal(t, "deployer", conf.Docker.Registries[0].Username)
+	assert.Equal(t, config.AWS{AWSAccessKey: "key", AWSSecretAccessKey: "secret", Session: "token"}, resolverAWS)
+}
+
+func TestNewConfigRejectsUnresolvedSecrets(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "env.yaml"), []byte(envFile), 0644))
+
+	_, err := config.NewConfig(dir, "env.yaml")
+	assert.EqualError(t, err, "unresolved secret references in docker.registries[0].password")
+}
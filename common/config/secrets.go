@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// SSMSecretPrefix marks a value stored in SSM Parameter Store, e.g. ssm:/deploy/ghcr/password
+	SSMSecretPrefix = "ssm:"
+	// SecretsManagerPrefix marks a value stored in Secrets Manager, e.g. secretsmanager:deploy/ghcr#password
+	// The part after # selects a key of a JSON secret, without it the whole secret string is used
+	SecretsManagerPrefix = "secretsmanager:"
+)
+
+// SecretResolver returns the value a secret reference points to
+type SecretResolver interface {
+	ResolveSecret(ref string) (string, error)
+}
+
+// IsSecretRef reports whether value references a secret instead of holding the value itself
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, SSMSecretPrefix) || strings.HasPrefix(value, SecretsManagerPrefix)
+}
+
+// ResolveSecrets replaces every secret reference in the config with the value it points to
+// AWS credentials are never resolved since the resolver needs them, leave them empty to use the instance role or environment instead
+func (c *Config) ResolveSecrets(resolver SecretResolver) error {
+	for name, value := range c.secretFields() {
+		if !IsSecretRef(*value) {
+			continue
+		}
+		resolved, err := resolver.ResolveSecret(*value)
+		if err != nil {
+			return fmt.Errorf("could not resolve %s: %w", name, err)
+		}
+		*value = resolved
+	}
+	return nil
+}
+
+// CheckResolved returns an error naming the config values that still hold a secret reference
+func (c *Config) CheckResolved() error {
+	var unresolved []string
+	for name, value := range c.secretFields() {
+		if IsSecretRef(*value) {
+			unresolved = append(unresolved, name)
+		}
+	}
+	if len(unresolved) == 0 {
+		return nil
+	}
+	sort.Strings(unresolved)
+	return fmt.Errorf("unresolved secret references in %s", strings.Join(unresolved, ", "))
+}
+
+// secretFields returns the config values that may hold a secret reference by their yaml path, add new secret values here
+func (c *Config) secretFields() map[string]*string {
+	fields := map[string]*string{}
+	for i := range c.Docker.Registries {
+		registry := &c.Docker.Registries[i]
+		fields[fmt.Sprintf("docker.registries[%d].username", i)] = &registry.Username
+		fields[fmt.Sprintf("docker.registries[%d].password", i)] = &registry.Password
+	}
+	return fields
+}
@@ -0,0 +1,257 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+	"log/slog"
+	"time"
+)
+
+const (
+	// defaultRefreshPollInterval is how often WaitForInstanceRefresh checks the refresh when PollInterval is zero
+	defaultRefreshPollInterval = 15 * time.Second
+	// maxGroupPageSize is the largest MaxRecords DescribeAutoScalingGroups accepts
+	maxGroupPageSize = 100
+)
+
+// AutoScalingAPI interface added in order to make mock testing easier, same as EC2API
+type AutoScalingAPI interface {
+	DescribeAutoScalingGroups(ctx context.Context, params *autoscaling.DescribeAutoScalingGroupsInput, optFns ...func(*autoscaling.Options)) (*autoscaling.DescribeAutoScalingGroupsOutput, error)
+	SetDesiredCapacity(ctx context.Context, params *autoscaling.SetDesiredCapacityInput, optFns ...func(*autoscaling.Options)) (*autoscaling.SetDesiredCapacityOutput, error)
+	SuspendProcesses(ctx context.Context, params *autoscaling.SuspendProcessesInput, optFns ...func(*autoscaling.Options)) (*autoscaling.SuspendProcessesOutput, error)
+	ResumeProcesses(ctx context.Context, params *autoscaling.ResumeProcessesInput, optFns ...func(*autoscaling.Options)) (*autoscaling.ResumeProcessesOutput, error)
+	DetachInstances(ctx context.Context, params *autoscaling.DetachInstancesInput, optFns ...func(*autoscaling.Options)) (*autoscaling.DetachInstancesOutput, error)
+	StartInstanceRefresh(ctx context.Context, params *autoscaling.StartInstanceRefreshInput, optFns ...func(*autoscaling.Options)) (*autoscaling.StartInstanceRefreshOutput, error)
+	DescribeInstanceRefreshes(ctx context.Context, params *autoscaling.DescribeInstanceRefreshesInput, optFns ...func(*autoscaling.Options)) (*autoscaling.DescribeInstanceRefreshesOutput, error)
+}
+
+// AutoScaling struct manages Auto Scaling groups, PageSize and PollInterval use the AWS default and 15 seconds when zero
+// PageSize is capped at the 100 groups per page AWS accepts
+type AutoScaling struct {
+	Client       AutoScalingAPI
+	PageSize     int32
+	PollInterval time.Duration
+}
+
+// InstanceRefreshOptions configures StartInstanceRefresh, zero values use the group or AWS defaults
+type InstanceRefreshOptions struct {
+	// MinHealthyPercentage is the share of the group that has to stay in service during the refresh
+	MinHealthyPercentage int32
+	// InstanceWarmup is the number of seconds until a new instance counts as healthy
+	InstanceWarmup int32
+	// SkipMatching leaves instances that already run the desired configuration alone
+	SkipMatching bool
+	AutoRollback bool
+}
+
+// InstanceRefreshProgress is the state of an instance refresh
+type InstanceRefreshProgress struct {
+	ID                 string
+	Status             types.InstanceRefreshStatus
+	StatusReason       string
+	PercentageComplete int32
+	InstancesToUpdate  int32
+}
+
+// Done reports whether the refresh stopped, successfully or not
+func (p InstanceRefreshProgress) Done() bool {
+	switch p.Status {
+	case types.InstanceRefreshStatusSuccessful, types.InstanceRefreshStatusFailed, types.InstanceRefreshStatusCancelled,
+		types.InstanceRefreshStatusRollbackSuccessful, types.InstanceRefreshStatusRollbackFailed:
+		return true
+	}
+	return false
+}
+
+// NewAutoScaling initializes new autoscaling client to use
+func NewAutoScaling(ctx context.Context, logger slog.Logger, accessKey, secretAccessKey, session string) *AutoScaling {
+	cfg := loadConfig(ctx, logger, accessKey, secretAccessKey, session)
+	return &AutoScaling{Client: autoscaling.NewFromConfig(cfg)}
+}
+
+// ListGroups returns the groups with the given names across all pages, no names returns every group in the region
+func (c *AutoScaling) ListGroups(names ...string) ([]types.AutoScalingGroup, error) {
+	input := &autoscaling.DescribeAutoScalingGroupsInput{AutoScalingGroupNames: names}
+	paginator := autoscaling.NewDescribeAutoScalingGroupsPaginator(c.Client, input, func(o *autoscaling.DescribeAutoScalingGroupsPaginatorOptions) {
+		o.Limit = c.pageSize()
+	})
+
+	var groups []types.AutoScalingGroup
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe auto scaling groups: %w", err)
+		}
+		groups = append(groups, page.AutoScalingGroups...)
+	}
+
+	return groups, nil
+}
+
+// GetGroup returns a single group by name
+func (c *AutoScaling) GetGroup(name string) (*types.AutoScalingGroup, error) {
+	groups, err := c.ListGroups(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("auto scaling group not found with name %s", name)
+	}
+	return &groups[0], nil
+}
+
+// GroupInstanceIDs returns the IDs of the instances in a group, inServiceOnly skips instances that are launching, terminating or in standby
+// The IDs can be passed to EC2.Query().IDs to get the full instances
+func (c *AutoScaling) GroupInstanceIDs(name string, inServiceOnly bool) ([]string, error) {
+	group, err := c.GetGroup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, instance := range group.Instances {
+		if inServiceOnly && instance.LifecycleState != types.LifecycleStateInService {
+			continue
+		}
+		ids = append(ids, aws.ToString(instance.InstanceId))
+	}
+	return ids, nil
+}
+
+// SetDesiredCapacity changes the number of instances of a group, honorCooldown rejects the change while the group is in cooldown
+func (c *AutoScaling) SetDesiredCapacity(name string, capacity int32, honorCooldown bool) error {
+	_, err := c.Client.SetDesiredCapacity(context.TODO(), &autoscaling.SetDesiredCapacityInput{
+		AutoScalingGroupName: aws.String(name),
+		DesiredCapacity:      aws.Int32(capacity),
+		HonorCooldown:        aws.Bool(honorCooldown),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set desired capacity of %s to %d: %w", name, capacity, err)
+	}
+	return nil
+}
+
+// SuspendProcesses suspends scaling processes such as Launch, Terminate or ReplaceUnhealthy, no processes suspends all of them
+func (c *AutoScaling) SuspendProcesses(name string, processes ...string) error {
+	_, err := c.Client.SuspendProcesses(context.TODO(), &autoscaling.SuspendProcessesInput{
+		AutoScalingGroupName: aws.String(name),
+		ScalingProcesses:     processes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to suspend processes of %s: %w", name, err)
+	}
+	return nil
+}
+
+// ResumeProcesses resumes suspended scaling processes, no processes resumes all of them
+func (c *AutoScaling) ResumeProcesses(name string, processes ...string) error {
+	_, err := c.Client.ResumeProcesses(context.TODO(), &autoscaling.ResumeProcessesInput{
+		AutoScalingGroupName: aws.String(name),
+		ScalingProcesses:     processes,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to resume processes of %s: %w", name, err)
+	}
+	return nil
+}
+
+// DetachInstances removes instances from a group without terminating them
+// decrementDesired lowers the desired capacity so the group doesn't launch replacements
+func (c *AutoScaling) DetachInstances(name string, instanceIDs []string, decrementDesired bool) error {
+	_, err := c.Client.DetachInstances(context.TODO(), &autoscaling.DetachInstancesInput{
+		AutoScalingGroupName:           aws.String(name),
+		InstanceIds:                    instanceIDs,
+		ShouldDecrementDesiredCapacity: aws.Bool(decrementDesired),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to detach instances %v from %s: %w", instanceIDs, name, err)
+	}
+	return nil
+}
+
+// StartInstanceRefresh replaces the instances of a group with the current launch template and returns the refresh ID
+func (c *AutoScaling) StartInstanceRefresh(name string, opts InstanceRefreshOptions) (string, error) {
+	preferences := &types.RefreshPreferences{
+		SkipMatching: aws.Bool(opts.SkipMatching),
+		AutoRollback: aws.Bool(opts.AutoRollback),
+	}
+	if opts.MinHealthyPercentage > 0 {
+		preferences.MinHealthyPercentage = aws.Int32(opts.MinHealthyPercentage)
+	}
+	if opts.InstanceWarmup > 0 {
+		preferences.InstanceWarmup = aws.Int32(opts.InstanceWarmup)
+	}
+
+	result, err := c.Client.StartInstanceRefresh(context.TODO(), &autoscaling.StartInstanceRefreshInput{
+		AutoScalingGroupName: aws.String(name),
+		Preferences:          preferences,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to start instance refresh of %s: %w", name, err)
+	}
+	return aws.ToString(result.InstanceRefreshId), nil
+}
+
+// InstanceRefreshStatus returns the progress of an instance refresh
+func (c *AutoScaling) InstanceRefreshStatus(name, refreshID string) (InstanceRefreshProgress, error) {
+	result, err := c.Client.DescribeInstanceRefreshes(context.TODO(), &autoscaling.DescribeInstanceRefreshesInput{
+		AutoScalingGroupName: aws.String(name),
+		InstanceRefreshIds:   []string{refreshID},
+	})
+	if err != nil {
+		return InstanceRefreshProgress{}, fmt.Errorf("failed to describe instance refresh %s of %s: %w", refreshID, name, err)
+	}
+	if len(result.InstanceRefreshes) == 0 {
+		return InstanceRefreshProgress{}, fmt.Errorf("instance refresh %s not found in %s", refreshID, name)
+	}
+
+	refresh := result.InstanceRefreshes[0]
+	return InstanceRefreshProgress{
+		ID:                 aws.ToString(refresh.InstanceRefreshId),
+		Status:             refresh.Status,
+		StatusReason:       aws.ToString(refresh.StatusReason),
+		PercentageComplete: aws.ToInt32(refresh.PercentageComplete),
+		InstancesToUpdate:  aws.ToInt32(refresh.InstancesToUpdate),
+	}, nil
+}
+
+// WaitForInstanceRefresh polls an instance refresh until it stops or timeout passes, progress is called after every poll and may be nil
+// Any final status other than Successful is returned as an error
+func (c *AutoScaling) WaitForInstanceRefresh(name, refreshID string, timeout time.Duration, progress func(InstanceRefreshProgress)) error {
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = defaultRefreshPollInterval
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		status, err := c.InstanceRefreshStatus(name, refreshID)
+		if err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(status)
+		}
+
+		if status.Done() {
+			if status.Status != types.InstanceRefreshStatusSuccessful {
+				return fmt.Errorf("instance refresh %s of %s ended with status %s: %s", refreshID, name, status.Status, status.StatusReason)
+			}
+			return nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("instance refresh %s of %s did not finish within %s, last status %s at %d%%", refreshID, name, timeout, status.Status, status.PercentageComplete)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func (c *AutoScaling) pageSize() int32 {
+	if c.PageSize <= 0 {
+		return 0
+	}
+	return min(c.PageSize, maxGroupPageSize)
+}
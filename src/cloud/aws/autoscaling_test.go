@@ -0,0 +1,217 @@
+package aws_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+	asgClient "github.com/onurcevik/deploy-utilities/src/cloud/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockAutoScalingAPI is a mock type for the AutoScalingAPI interface
+type MockAutoScalingAPI struct {
+	mock.Mock
+}
+
+func (m *MockAutoScalingAPI) DescribeAutoScalingGroups(ctx context.Context, params *autoscaling.DescribeAutoScalingGroupsInput, optFns ...func(*autoscaling.Options)) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*autoscaling.DescribeAutoScalingGroupsOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockAutoScalingAPI) SetDesiredCapacity(ctx context.Context, params *autoscaling.SetDesiredCapacityInput, optFns ...func(*autoscaling.Options)) (*autoscaling.SetDesiredCapacityOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*autoscaling.SetDesiredCapacityOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockAutoScalingAPI) SuspendProcesses(ctx context.Context, params *autoscaling.SuspendProcessesInput, optFns ...func(*autoscaling.Options)) (*autoscaling.SuspendProcessesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*autoscaling.SuspendProcessesOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockAutoScalingAPI) ResumeProcesses(ctx context.Context, params *autoscaling.ResumeProcessesInput, optFns ...func(*autoscaling.Options)) (*autoscaling.ResumeProcessesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*autoscaling.ResumeProcessesOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockAutoScalingAPI) DetachInstances(ctx context.Context, params *autoscaling.DetachInstancesInput, optFns ...func(*autoscaling.Options)) (*autoscaling.DetachInstancesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*autoscaling.DetachInstancesOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockAutoScalingAPI) StartInstanceRefresh(ctx context.Context, params *autoscaling.StartInstanceRefreshInput, optFns ...func(*autoscaling.Options)) (*autoscaling.StartInstanceRefreshOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*autoscaling.StartInstanceRefreshOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockAutoScalingAPI) DescribeInstanceRefreshes(ctx context.Context, params *autoscaling.DescribeInstanceRefreshesInput, optFns ...func(*autoscaling.Options)) (*autoscaling.DescribeInstanceRefreshesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*autoscaling.DescribeInstanceRefreshesOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func TestListGroupsPaginates(t *testing.T) {
+	mockASG := new(MockAutoScalingAPI)
+	client := &asgClient.AutoScaling{Client: mockASG}
+
+	mockASG.On("DescribeAutoScalingGroups", mock.Anything, mock.MatchedBy(func(input *autoscaling.DescribeAutoScalingGroupsInput) bool {
+		return input.NextToken == nil
+	}), mock.Anything).Return(&autoscaling.DescribeAutoScalingGroupsOutput{
+		AutoScalingGroups: []types.AutoScalingGroup{{AutoScalingGroupName: aws.String("api")}},
+		NextToken:         aws.String("page-2"),
+	}, nil).Once()
+	mockASG.On("DescribeAutoScalingGroups", mock.Anything, mock.MatchedBy(func(input *autoscaling.DescribeAutoScalingGroupsInput) bool {
+		return aws.ToString(input.NextToken) == "page-2"
+	}), mock.Anything).Return(&autoscaling.DescribeAutoScalingGroupsOutput{
+		AutoScalingGroups: []types.AutoScalingGroup{{AutoScalingGroupName: aws.String("worker")}},
+	}, nil).Once()
+
+	groups, err := client.ListGroups()
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, "worker", aws.ToString(groups[1].AutoScalingGroupName))
+	mockASG.AssertExpectations(t)
+}
+
+func TestListGroupsClampsPageSize(t *testing.T) {
+	mockASG := new(MockAutoScalingAPI)
+	client := &asgClient.AutoScaling{Client: mockASG, PageSize: 500}
+
+	mockASG.On("DescribeAutoScalingGroups", mock.Anything, mock.MatchedBy(func(input *autoscaling.DescribeAutoScalingGroupsInput) bool {
+		return aws.ToInt32(input.MaxRecords) == 100
+	}), mock.Anything).Return(&autoscaling.DescribeAutoScalingGroupsOutput{}, nil).Once()
+
+	_, err := client.ListGroups()
+	require.NoError(t, err)
+	mockASG.AssertExpectations(t)
+}
+
+func TestGroupInstanceIDs(t *testing.T) {
+	mockASG := new(MockAutoScalingAPI)
+	client := &asgClient.AutoScaling{Client: mockASG}
+
+	mockASG.On("DescribeAutoScalingGroups", mock.Anything, &autoscaling.DescribeAutoScalingGroupsInput{AutoScalingGroupNames: []string{"api"}}, mock.Anything).
+		Return(&autoscaling.DescribeAutoScalingGroupsOutput{AutoScalingGroups: []types.AutoScalingGroup{{
+			AutoScalingGroupName: aws.String("api"),
+			Instances: []types.Instance{
+				{InstanceId: aws.String("i-1"), LifecycleState: types.LifecycleStateInService},
+				{InstanceId: aws.String("i-2"), LifecycleState: types.LifecycleStatePending},
+			},
+		}}}, nil)
+
+	ids, err := client.GroupInstanceIDs("api", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i-1"}, ids)
+
+	ids, err = client.GroupInstanceIDs("api", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"i-1", "i-2"}, ids)
+}
+
+func TestGetGroupNotFound(t *testing.T) {
+	mockASG := new(MockAutoScalingAPI)
+	client := &asgClient.AutoScaling{Client: mockASG}
+
+	mockASG.On("DescribeAutoScalingGroups", mock.Anything, mock.Anything, mock.Anything).Return(&autoscaling.DescribeAutoScalingGroupsOutput{}, nil)
+
+	_, err := client.GetGroup("api")
+	assert.ErrorContains(t, err, "auto scaling group not found with name api")
+}
+
+func TestInstanceRefresh(t *testing.T) {
+	mockASG := new(MockAutoScalingAPI)
+	client := &asgClient.AutoScaling{Client: mockASG, PollInterval: time.Millisecond}
+
+	mockASG.On("StartInstanceRefresh", mock.Anything, mock.MatchedBy(func(input *autoscaling.StartInstanceRefreshInput) bool {
+		return aws.ToString(input.AutoScalingGroupName) == "api" &&
+			aws.ToInt32(input.Preferences.MinHealthyPercentage) == 90 &&
+			aws.ToBool(input.Preferences.SkipMatching)
+	}), mock.Anything).Return(&autoscaling.StartInstanceRefreshOutput{InstanceRefreshId: aws.String("refresh-1")}, nil)
+
+	refresh := func(status types.InstanceRefreshStatus, percentage int32) *autoscaling.DescribeInstanceRefreshesOutput {
+		return &autoscaling.DescribeInstanceRefreshesOutput{InstanceRefreshes: []types.InstanceRefresh{{
+			InstanceRefreshId:  aws.String("refresh-1"),
+			Status:             status,
+			PercentageComplete: aws.Int32(percentage),
+		}}}
+	}
+	mockASG.On("DescribeInstanceRefreshes", mock.Anything, mock.Anything, mock.Anything).Return(refresh(types.InstanceRefreshStatusPending, 0), nil).Once()
+	mockASG.On("DescribeInstanceRefreshes", mock.Anything, mock.Anything, mock.Anything).Return(refresh(types.InstanceRefreshStatusInProgress, 50), nil).Once()
+	mockASG.On("DescribeInstanceRefreshes", mock.Anything, mock.Anything, mock.Anything).Return(refresh(types.InstanceRefreshStatusSuccessful, 100), nil).Once()
+
+	id, err := client.StartInstanceRefresh("api", asgClient.InstanceRefreshOptions{MinHealthyPercentage: 90, SkipMatching: true})
+	require.NoError(t, err)
+	assert.Equal(t, "refresh-1", id)
+
+	var percentages []int32
+	err = client.WaitForInstanceRefresh("api", id, time.Second, func(p asgClient.InstanceRefreshProgress) {
+		percentages = append(percentages, p.PercentageComplete)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int32{0, 50, 100}, percentages)
+}
+
+func TestWaitForInstanceRefreshFailed(t *testing.T) {
+	mockASG := new(MockAutoScalingAPI)
+	client := &asgClient.AutoScaling{Client: mockASG, PollInterval: time.Millisecond}
+
+	mockASG.On("DescribeInstanceRefreshes", mock.Anything, mock.Anything, mock.Anything).Return(&autoscaling.DescribeInstanceRefreshesOutput{
+		InstanceRefreshes: []types.InstanceRefresh{{
+			InstanceRefreshId: aws.String("refresh-1"),
+			Status:            types.InstanceRefreshStatusRollbackSuccessful,
+			StatusReason:      aws.String("new instances failed health checks"),
+		}},
+	}, nil)
+
+	err := client.WaitForInstanceRefresh("api", "refresh-1", time.Second, nil)
+	assert.ErrorContains(t, err, "ended with status RollbackSuccessful: new instances failed health checks")
+}
+
+func TestSuspendAndResumeProcesses(t *testing.T) {
+	mockASG := new(MockAutoScalingAPI)
+	client := &asgClient.AutoScaling{Client: mockASG}
+
+	mockASG.On("SuspendProcesses", mock.Anything, &autoscaling.SuspendProcessesInput{
+		AutoScalingGroupName: aws.String("api"),
+		ScalingProcesses:     []string{"ReplaceUnhealthy", "AZRebalance"},
+	}, mock.Anything).Return(&autoscaling.SuspendProcessesOutput{}, nil)
+	mockASG.On("ResumeProcesses", mock.Anything, &autoscaling.ResumeProcessesInput{
+		AutoScalingGroupName: aws.String("api"),
+	}, mock.Anything).Return(&autoscaling.ResumeProcessesOutput{}, nil)
+
+	require.NoError(t, client.SuspendProcesses("api", "ReplaceUnhealthy", "AZRebalance"))
+	require.NoError(t, client.ResumeProcesses("api"))
+	mockASG.AssertExpectations(t)
+}
@@ -2,14 +2,22 @@ package aws
 
 import (
 	"context"
+	sdkaws "github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/onurcevik/deploy-utilities/common/config"
 	"log/slog"
 )
 
 // AWSClient holds fields for logger and AWS services
 type AWSClient struct {
-	Logger slog.Logger
-	EC2    *EC2
+	Logger      slog.Logger
+	EC2         *EC2
+	ECR         *ECR
+	AutoScaling *AutoScaling
+	ELB         *ELB
+	SSM         *SSM
+	S3          *S3
 }
 
 // NewAWSClient initializes AWSClient and its service fields
@@ -17,5 +25,25 @@ func NewAWSClient(ctx context.Context, logger slog.Logger, conf config.Config) *
 	aws := new(AWSClient)
 	aws.Logger = logger
 	aws.EC2 = NewEC2(ctx, logger, conf.AWS.AWSAccessKey, conf.AWS.AWSSecretAccessKey, conf.AWS.Session)
+	aws.ECR = NewECR(ctx, logger, conf.AWS.AWSAccessKey, conf.AWS.AWSSecretAccessKey, conf.AWS.Session)
+	aws.AutoScaling = NewAutoScaling(ctx, logger, conf.AWS.AWSAccessKey, conf.AWS.AWSSecretAccessKey, conf.AWS.Session)
+	aws.ELB = NewELB(ctx, logger, conf.AWS.AWSAccessKey, conf.AWS.AWSSecretAccessKey, conf.AWS.Session)
+	aws.SSM = NewSSM(ctx, logger, conf.AWS.AWSAccessKey, conf.AWS.AWSSecretAccessKey, conf.AWS.Session)
+	aws.S3 = NewS3(ctx, logger, conf.AWS.AWSAccessKey, conf.AWS.AWSSecretAccessKey, conf.AWS.Session)
 	return aws
 }
+
+// loadConfig loads the shared aws sdk config with static credentials, errors are logged and an empty config is returned
+// Without an access key the default credential chain is used, e.g. environment variables or the instance role
+func loadConfig(ctx context.Context, logger slog.Logger, accessKey, secretAccessKey, session string) sdkaws.Config {
+	var opts []func(*awsconfig.LoadOptions) error
+	if accessKey != "" {
+		creds := credentials.NewStaticCredentialsProvider(accessKey, secretAccessKey, session)
+		opts = append(opts, awsconfig.WithCredentialsProvider(creds))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		logger.Error("error reading AWS config", "error", err)
+	}
+	return cfg
+}
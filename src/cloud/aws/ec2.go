@@ -4,96 +4,130 @@ import (
 	"context"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"log/slog"
+	"time"
 )
 
 // EC2API interface added in order to make mock testing easier if future helper functions require more aws functions they should be added below interface
 type EC2API interface {
 	DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error)
+	DescribeInstanceStatus(ctx context.Context, params *ec2.DescribeInstanceStatusInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceStatusOutput, error)
+	StartInstances(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error)
+	StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error)
+	RebootInstances(ctx context.Context, params *ec2.RebootInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RebootInstancesOutput, error)
+	TerminateInstances(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error)
+	RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error)
+	DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error)
 }
 
-// EC2 struct implements ec2.DescribeInstancesAPIClient from aws sdk which allows us to test its functions using the EC2API interface above
+const (
+	// minPageSize and maxPageSize are the MaxResults bounds of DescribeInstances
+	minPageSize = 5
+	maxPageSize = 1000
+)
+
+// EC2 struct uses the EC2API interface above as its client which allows us to test its functions with a mock
+// PageSize is the number of results requested per page, zero or less uses the AWS default and other values are clamped to the 5 to 1000 AWS accepts
+// PollInterval is how often waits check the instances, zero uses the 15 second AWS waiter delay
 type EC2 struct {
-	Client ec2.DescribeInstancesAPIClient
+	Client       EC2API
+	PageSize     int32
+	PollInterval time.Duration
 }
 
 // NewEC2 initializes new ec2 client to use
 func NewEC2(ctx context.Context, logger slog.Logger, accessKey, secretAccessKey, session string) *EC2 {
-	creds := credentials.NewStaticCredentialsProvider(accessKey, secretAccessKey, session)
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithCredentialsProvider(creds))
-	if err != nil {
-
-		logger.Error("error reading AWS config", err)
-	}
+	cfg := loadConfig(ctx, logger, accessKey, secretAccessKey, session)
 	return &EC2{Client: ec2.NewFromConfig(cfg)}
 }
 
 func (c *EC2) GetInstanceByID(instanceID string) (*types.Instance, error) {
-	input := &ec2.DescribeInstancesInput{
+	instances, err := c.describeInstances(&ec2.DescribeInstancesInput{
 		InstanceIds: []string{instanceID},
-	}
-
-	result, err := c.Client.DescribeInstances(context.TODO(), input)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe instances: %w", err)
+		return nil, err
 	}
 
-	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
+	if len(instances) == 0 {
 		return nil, fmt.Errorf("instance not found with ID %s", instanceID)
 	}
 
-	return &result.Reservations[0].Instances[0], nil
+	return &instances[0], nil
 }
 
 func (c *EC2) GetInstanceByPrivateIP(privateIP string) (*types.Instance, error) {
-	input := &ec2.DescribeInstancesInput{
+	instances, err := c.describeInstances(&ec2.DescribeInstancesInput{
 		Filters: []types.Filter{
 			{
 				Name:   aws.String("private-ip-address"),
 				Values: []string{privateIP},
 			},
 		},
-	}
-
-	result, err := c.Client.DescribeInstances(context.TODO(), input)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe instances: %w", err)
+		return nil, err
 	}
 
-	if len(result.Reservations) == 0 || len(result.Reservations[0].Instances) == 0 {
+	if len(instances) == 0 {
 		return nil, fmt.Errorf("instance not found with private IP %s", privateIP)
 	}
 
-	return &result.Reservations[0].Instances[0], nil
+	return &instances[0], nil
 }
 
 func (c *EC2) GetInstancesByTag(tagKey, tagValue string) ([]types.Instance, error) {
-	input := &ec2.DescribeInstancesInput{
+	instances, err := c.describeInstances(&ec2.DescribeInstancesInput{
 		Filters: []types.Filter{
 			{
 				Name:   aws.String("tag:" + tagKey),
 				Values: []string{tagValue},
 			},
 		},
-	}
-
-	result, err := c.Client.DescribeInstances(context.TODO(), input)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe instances: %w", err)
+		return nil, err
 	}
 
-	if len(result.Reservations) == 0 {
+	if len(instances) == 0 {
 		return nil, fmt.Errorf("no instances found with tag %s=%s", tagKey, tagValue)
 	}
 
+	return instances, nil
+}
+
+// describeInstances follows NextToken through every page and returns the instances of all reservations
+func (c *EC2) describeInstances(input *ec2.DescribeInstancesInput) ([]types.Instance, error) {
+	paginator := c.instancePaginator(input)
+
 	var instances []types.Instance
-	for _, reservation := range result.Reservations {
-		instances = append(instances, reservation.Instances...)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe instances: %w", err)
+		}
+		for _, reservation := range page.Reservations {
+			instances = append(instances, reservation.Instances...)
+		}
 	}
 
 	return instances, nil
 }
+
+func (c *EC2) instancePaginator(input *ec2.DescribeInstancesInput) *ec2.DescribeInstancesPaginator {
+	return ec2.NewDescribeInstancesPaginator(c.Client, input, func(o *ec2.DescribeInstancesPaginatorOptions) {
+		// AWS rejects MaxResults together with instance IDs, all requested IDs are returned in one page anyway
+		if len(input.InstanceIds) == 0 {
+			o.Limit = c.pageSize()
+		}
+	})
+}
+
+func (c *EC2) pageSize() int32 {
+	if c.PageSize <= 0 {
+		return 0
+	}
+	return min(max(c.PageSize, minPageSize), maxPageSize)
+}
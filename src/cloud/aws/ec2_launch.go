@@ -0,0 +1,151 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"sort"
+	"time"
+)
+
+// LaunchSpec describes instances to launch, either from a launch template or from an explicit AMI and instance type
+// Fields set next to a launch template override the values of the template
+type LaunchSpec struct {
+	LaunchTemplateID   string
+	LaunchTemplateName string
+	// LaunchTemplateVersion defaults to the template default version
+	LaunchTemplateVersion string
+
+	ImageID          string
+	InstanceType     types.InstanceType
+	SubnetID         string
+	SecurityGroupIDs []string
+	KeyName          string
+	// UserData is the plain script, it is base64 encoded before it is sent
+	UserData string
+	// IAMInstanceProfile is the name of the instance profile
+	IAMInstanceProfile string
+	// Tags are added to the instances and their volumes
+	Tags map[string]string
+	// Count defaults to 1, either all instances are launched or none
+	Count int32
+
+	// SkipStatusChecks returns as soon as the instances are running instead of waiting for the status checks to pass
+	SkipStatusChecks bool
+	// WaitTimeout applies to each wait, defaults to 10 minutes
+	WaitTimeout time.Duration
+}
+
+// LaunchInstances runs instances from spec and waits until they are running and their status checks pass
+// The instances are described again after the waits so their IP addresses are filled in
+// When a wait fails the launched instances are returned with the error so the caller can terminate them
+func (c *EC2) LaunchInstances(spec LaunchSpec) ([]types.Instance, error) {
+	input, err := spec.runInstancesInput()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.Client.RunInstances(context.TODO(), input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run instances: %w", err)
+	}
+
+	ids := make([]string, 0, len(result.Instances))
+	for _, instance := range result.Instances {
+		ids = append(ids, aws.ToString(instance.InstanceId))
+	}
+
+	timeout := waitTimeout(LifecycleOptions{WaitTimeout: spec.WaitTimeout})
+	err = ec2.NewInstanceRunningWaiter(c.Client, func(o *ec2.InstanceRunningWaiterOptions) {
+		if c.PollInterval > 0 {
+			o.MinDelay, o.MaxDelay = c.PollInterval, c.PollInterval
+		}
+	}).Wait(context.TODO(), &ec2.DescribeInstancesInput{InstanceIds: ids}, timeout)
+	if err != nil {
+		return result.Instances, fmt.Errorf("instances %v did not reach running state: %w", ids, err)
+	}
+	if !spec.SkipStatusChecks {
+		err = ec2.NewInstanceStatusOkWaiter(c.Client, func(o *ec2.InstanceStatusOkWaiterOptions) {
+			if c.PollInterval > 0 {
+				o.MinDelay, o.MaxDelay = c.PollInterval, c.PollInterval
+			}
+		}).Wait(context.TODO(), &ec2.DescribeInstanceStatusInput{InstanceIds: ids}, timeout)
+		if err != nil {
+			return result.Instances, fmt.Errorf("instances %v did not pass status checks: %w", ids, err)
+		}
+	}
+
+	instances, err := c.describeInstances(&ec2.DescribeInstancesInput{InstanceIds: ids})
+	if err != nil {
+		return result.Instances, err
+	}
+	return instances, nil
+}
+
+func (spec LaunchSpec) runInstancesInput() (*ec2.RunInstancesInput, error) {
+	hasTemplate := spec.LaunchTemplateID != "" || spec.LaunchTemplateName != ""
+	if !hasTemplate && (spec.ImageID == "" || spec.InstanceType == "") {
+		return nil, fmt.Errorf("launch spec needs a launch template or an image ID and instance type")
+	}
+
+	count := spec.Count
+	if count <= 0 {
+		count = 1
+	}
+
+	input := &ec2.RunInstancesInput{
+		MinCount: aws.Int32(count),
+		MaxCount: aws.Int32(count),
+	}
+	if hasTemplate {
+		input.LaunchTemplate = &types.LaunchTemplateSpecification{}
+		if spec.LaunchTemplateID != "" {
+			input.LaunchTemplate.LaunchTemplateId = aws.String(spec.LaunchTemplateID)
+		} else {
+			input.LaunchTemplate.LaunchTemplateName = aws.String(spec.LaunchTemplateName)
+		}
+		if spec.LaunchTemplateVersion != "" {
+			input.LaunchTemplate.Version = aws.String(spec.LaunchTemplateVersion)
+		}
+	}
+
+	if spec.ImageID != "" {
+		input.ImageId = aws.String(spec.ImageID)
+	}
+	input.InstanceType = spec.InstanceType
+	if spec.SubnetID != "" {
+		input.SubnetId = aws.String(spec.SubnetID)
+	}
+	input.SecurityGroupIds = spec.SecurityGroupIDs
+	if spec.KeyName != "" {
+		input.KeyName = aws.String(spec.KeyName)
+	}
+	if spec.UserData != "" {
+		input.UserData = aws.String(base64.StdEncoding.EncodeToString([]byte(spec.UserData)))
+	}
+	if spec.IAMInstanceProfile != "" {
+		input.IamInstanceProfile = &types.IamInstanceProfileSpecification{Name: aws.String(spec.IAMInstanceProfile)}
+	}
+
+	if len(spec.Tags) > 0 {
+		keys := make([]string, 0, len(spec.Tags))
+		for key := range spec.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		tags := make([]types.Tag, 0, len(keys))
+		for _, key := range keys {
+			tags = append(tags, types.Tag{Key: aws.String(key), Value: aws.String(spec.Tags[key])})
+		}
+		input.TagSpecifications = []types.TagSpecification{
+			{ResourceType: types.ResourceTypeInstance, Tags: tags},
+			{ResourceType: types.ResourceTypeVolume, Tags: tags},
+		}
+	}
+
+	return input, nil
+}
@@ -0,0 +1,115 @@
+package aws_test
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ec2Client "github.com/onurcevik/deploy-utilities/src/cloud/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLaunchInstancesFromSpec(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2}
+
+	mockEC2.On("RunInstances", mock.Anything, mock.MatchedBy(func(input *ec2.RunInstancesInput) bool {
+		userData, _ := base64.StdEncoding.DecodeString(aws.ToString(input.UserData))
+		return aws.ToString(input.ImageId) == "ami-123" &&
+			input.InstanceType == types.InstanceTypeT3Small &&
+			aws.ToInt32(input.MinCount) == 2 && aws.ToInt32(input.MaxCount) == 2 &&
+			aws.ToString(input.KeyName) == "deploy" &&
+			string(userData) == "#!/bin/sh\necho hi\n" &&
+			len(input.TagSpecifications) == 2 &&
+			aws.ToString(input.TagSpecifications[0].Tags[0].Key) == "env"
+	}), mock.Anything).Return(&ec2.RunInstancesOutput{
+		Instances: []types.Instance{{InstanceId: aws.String("i-1")}, {InstanceId: aws.String("i-2")}},
+	}, nil)
+
+	running := instancesInState(types.InstanceStateNameRunning, "i-1", "i-2")
+	running.Reservations[0].Instances[0].PrivateIpAddress = aws.String("10.0.1.10")
+	mockEC2.On("DescribeInstances", mock.Anything, mock.Anything, mock.Anything).Return(running, nil)
+	mockEC2.On("DescribeInstanceStatus", mock.Anything, &ec2.DescribeInstanceStatusInput{InstanceIds: []string{"i-1", "i-2"}}, mock.Anything).
+		Return(&ec2.DescribeInstanceStatusOutput{InstanceStatuses: []types.InstanceStatus{
+			{InstanceId: aws.String("i-1"), InstanceStatus: &types.InstanceStatusSummary{Status: types.SummaryStatusOk}},
+			{InstanceId: aws.String("i-2"), InstanceStatus: &types.InstanceStatusSummary{Status: types.SummaryStatusOk}},
+		}}, nil)
+
+	instances, err := client.LaunchInstances(ec2Client.LaunchSpec{
+		ImageID:      "ami-123",
+		InstanceType: types.InstanceTypeT3Small,
+		KeyName:      "deploy",
+		UserData:     "#!/bin/sh\necho hi\n",
+		Tags:         map[string]string{"env": "test", "owner": "ci"},
+		Count:        2,
+	})
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+	assert.Equal(t, "10.0.1.10", aws.ToString(instances[0].PrivateIpAddress))
+	mockEC2.AssertExpectations(t)
+}
+
+func TestLaunchInstancesFromTemplate(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2}
+
+	mockEC2.On("RunInstances", mock.Anything, mock.MatchedBy(func(input *ec2.RunInstancesInput) bool {
+		return aws.ToString(input.LaunchTemplate.LaunchTemplateName) == "api-node" &&
+			aws.ToString(input.LaunchTemplate.Version) == "$Latest" &&
+			input.ImageId == nil && aws.ToInt32(input.MinCount) == 1
+	}), mock.Anything).Return(&ec2.RunInstancesOutput{Instances: []types.Instance{{InstanceId: aws.String("i-1")}}}, nil)
+	mockEC2.On("DescribeInstances", mock.Anything, mock.Anything, mock.Anything).Return(instancesInState(types.InstanceStateNameRunning, "i-1"), nil)
+
+	instances, err := client.LaunchInstances(ec2Client.LaunchSpec{
+		LaunchTemplateName:    "api-node",
+		LaunchTemplateVersion: "$Latest",
+		SkipStatusChecks:      true,
+	})
+	require.NoError(t, err)
+	assert.Len(t, instances, 1)
+	mockEC2.AssertNotCalled(t, "DescribeInstanceStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLaunchInstancesWaitsUsePollInterval(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2, PollInterval: time.Millisecond}
+
+	mockEC2.On("RunInstances", mock.Anything, mock.Anything, mock.Anything).Return(&ec2.RunInstancesOutput{Instances: []types.Instance{{InstanceId: aws.String("i-1")}}}, nil)
+	mockEC2.On("DescribeInstances", mock.Anything, mock.Anything, mock.Anything).Return(instancesInState(types.InstanceStateNamePending, "i-1"), nil).Once()
+	mockEC2.On("DescribeInstances", mock.Anything, mock.Anything, mock.Anything).Return(instancesInState(types.InstanceStateNameRunning, "i-1"), nil)
+	mockEC2.On("DescribeInstanceStatus", mock.Anything, mock.Anything, mock.Anything).Return(instanceStatus("i-1", types.SummaryStatusInitializing), nil).Once()
+	mockEC2.On("DescribeInstanceStatus", mock.Anything, mock.Anything, mock.Anything).Return(instanceStatus("i-1", types.SummaryStatusOk), nil).Once()
+
+	// Both waiters would sleep 15 seconds between checks without PollInterval
+	started := time.Now()
+	_, err := client.LaunchInstances(ec2Client.LaunchSpec{ImageID: "ami-123", InstanceType: types.InstanceTypeT3Small})
+	require.NoError(t, err)
+	assert.Less(t, time.Since(started), 5*time.Second)
+	mockEC2.AssertExpectations(t)
+}
+
+func TestLaunchInstancesReturnsInstancesWhenWaitFails(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2}
+
+	mockEC2.On("RunInstances", mock.Anything, mock.Anything, mock.Anything).Return(&ec2.RunInstancesOutput{Instances: []types.Instance{{InstanceId: aws.String("i-1")}}}, nil)
+	// The running waiter fails right away on terminated instances, for example when the AMI can't boot
+	mockEC2.On("DescribeInstances", mock.Anything, mock.Anything, mock.Anything).Return(instancesInState(types.InstanceStateNameTerminated, "i-1"), nil)
+
+	instances, err := client.LaunchInstances(ec2Client.LaunchSpec{ImageID: "ami-123", InstanceType: types.InstanceTypeT3Small})
+	assert.ErrorContains(t, err, "did not reach running state")
+	require.Len(t, instances, 1)
+	assert.Equal(t, "i-1", aws.ToString(instances[0].InstanceId))
+}
+
+func TestLaunchInstancesValidatesSpec(t *testing.T) {
+	client := &ec2Client.EC2{Client: new(MockEC2API)}
+
+	_, err := client.LaunchInstances(ec2Client.LaunchSpec{ImageID: "ami-123"})
+	assert.ErrorContains(t, err, "needs a launch template or an image ID and instance type")
+}
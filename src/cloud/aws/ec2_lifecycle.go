@@ -0,0 +1,193 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	"sort"
+	"time"
+)
+
+const (
+	// defaultWaitTimeout is how long lifecycle operations wait for the target state when LifecycleOptions.WaitTimeout is zero
+	defaultWaitTimeout = 10 * time.Minute
+	// defaultPollInterval is the delay between checks of waits that don't use an AWS waiter, same as the AWS waiter default
+	defaultPollInterval = 15 * time.Second
+)
+
+// LifecycleOptions changes how instance lifecycle operations run
+type LifecycleOptions struct {
+	// DryRun only checks permissions and parameters, nothing is changed and nothing is waited for
+	DryRun bool
+	// Wait blocks until the instances reach the target state, reboot waits for the status checks to pass
+	Wait        bool
+	WaitTimeout time.Duration
+	// Force stops instances without a graceful shutdown, it is only used by StopInstances
+	Force bool
+}
+
+// StartInstances starts stopped instances, with Wait it returns once they are running
+func (c *EC2) StartInstances(instanceIDs []string, opts LifecycleOptions) error {
+	_, err := c.Client.StartInstances(context.TODO(), &ec2.StartInstancesInput{
+		InstanceIds: instanceIDs,
+		DryRun:      aws.Bool(opts.DryRun),
+	})
+	if done, err := lifecycleResult("start", instanceIDs, opts, err); done {
+		return err
+	}
+
+	err = ec2.NewInstanceRunningWaiter(c.Client, func(o *ec2.InstanceRunningWaiterOptions) {
+		if c.PollInterval > 0 {
+			o.MinDelay, o.MaxDelay = c.PollInterval, c.PollInterval
+		}
+	}).Wait(context.TODO(), &ec2.DescribeInstancesInput{InstanceIds: instanceIDs}, waitTimeout(opts))
+	if err != nil {
+		return fmt.Errorf("instances %v did not reach running state: %w", instanceIDs, err)
+	}
+	return nil
+}
+
+// StopInstances stops running instances, with Wait it returns once they are stopped
+func (c *EC2) StopInstances(instanceIDs []string, opts LifecycleOptions) error {
+	_, err := c.Client.StopInstances(context.TODO(), &ec2.StopInstancesInput{
+		InstanceIds: instanceIDs,
+		DryRun:      aws.Bool(opts.DryRun),
+		Force:       aws.Bool(opts.Force),
+	})
+	if done, err := lifecycleResult("stop", instanceIDs, opts, err); done {
+		return err
+	}
+
+	err = ec2.NewInstanceStoppedWaiter(c.Client, func(o *ec2.InstanceStoppedWaiterOptions) {
+		if c.PollInterval > 0 {
+			o.MinDelay, o.MaxDelay = c.PollInterval, c.PollInterval
+		}
+	}).Wait(context.TODO(), &ec2.DescribeInstancesInput{InstanceIds: instanceIDs}, waitTimeout(opts))
+	if err != nil {
+		return fmt.Errorf("instances %v did not reach stopped state: %w", instanceIDs, err)
+	}
+	return nil
+}
+
+// RebootInstances reboots instances, with Wait it returns once their status checks pass again
+// The status checks are still ok right after the reboot request, so Wait first waits for every instance to leave ok
+// A reboot that completes between two checks is not noticed and the wait times out, keep PollInterval short for fast booting instances
+func (c *EC2) RebootInstances(instanceIDs []string, opts LifecycleOptions) error {
+	_, err := c.Client.RebootInstances(context.TODO(), &ec2.RebootInstancesInput{
+		InstanceIds: instanceIDs,
+		DryRun:      aws.Bool(opts.DryRun),
+	})
+	if done, err := lifecycleResult("reboot", instanceIDs, opts, err); done {
+		return err
+	}
+
+	deadline := time.Now().Add(waitTimeout(opts))
+	if err := c.waitForRebootStarted(instanceIDs, deadline); err != nil {
+		return err
+	}
+
+	err = ec2.NewInstanceStatusOkWaiter(c.Client, func(o *ec2.InstanceStatusOkWaiterOptions) {
+		if c.PollInterval > 0 {
+			o.MinDelay, o.MaxDelay = c.PollInterval, c.PollInterval
+		}
+	}).Wait(context.TODO(), &ec2.DescribeInstanceStatusInput{InstanceIds: instanceIDs}, time.Until(deadline))
+	if err != nil {
+		return fmt.Errorf("instances %v did not pass status checks after reboot: %w", instanceIDs, err)
+	}
+	return nil
+}
+
+// waitForRebootStarted polls until every instance was seen with status checks other than ok, or not running at all
+func (c *EC2) waitForRebootStarted(instanceIDs []string, deadline time.Time) error {
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	pending := map[string]bool{}
+	for _, id := range instanceIDs {
+		pending[id] = true
+	}
+
+	for {
+		result, err := c.Client.DescribeInstanceStatus(context.TODO(), &ec2.DescribeInstanceStatusInput{
+			InstanceIds:         instanceIDs,
+			IncludeAllInstances: aws.Bool(true),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to describe status of instances %v: %w", instanceIDs, err)
+		}
+
+		stillOk := map[string]bool{}
+		for _, status := range result.InstanceStatuses {
+			running := status.InstanceState != nil && status.InstanceState.Name == types.InstanceStateNameRunning
+			ok := status.InstanceStatus != nil && status.InstanceStatus.Status == types.SummaryStatusOk
+			if running && ok {
+				stillOk[aws.ToString(status.InstanceId)] = true
+			}
+		}
+		for id := range pending {
+			if !stillOk[id] {
+				delete(pending, id)
+			}
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			var ids []string
+			for id := range pending {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			return fmt.Errorf("instances %v did not start rebooting, their status checks stayed ok", ids)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// TerminateInstances terminates instances, with Wait it returns once they are terminated
+func (c *EC2) TerminateInstances(instanceIDs []string, opts LifecycleOptions) error {
+	_, err := c.Client.TerminateInstances(context.TODO(), &ec2.TerminateInstancesInput{
+		InstanceIds: instanceIDs,
+		DryRun:      aws.Bool(opts.DryRun),
+	})
+	if done, err := lifecycleResult("terminate", instanceIDs, opts, err); done {
+		return err
+	}
+
+	err = ec2.NewInstanceTerminatedWaiter(c.Client, func(o *ec2.InstanceTerminatedWaiterOptions) {
+		if c.PollInterval > 0 {
+			o.MinDelay, o.MaxDelay = c.PollInterval, c.PollInterval
+		}
+	}).Wait(context.TODO(), &ec2.DescribeInstancesInput{InstanceIds: instanceIDs}, waitTimeout(opts))
+	if err != nil {
+		return fmt.Errorf("instances %v did not reach terminated state: %w", instanceIDs, err)
+	}
+	return nil
+}
+
+// lifecycleResult handles the result of a lifecycle request, done is false when the caller should wait for the target state
+// A dry run that would have succeeded is reported by AWS as a DryRunOperation error, it is not an error here
+func lifecycleResult(action string, instanceIDs []string, opts LifecycleOptions, err error) (bool, error) {
+	var apiErr smithy.APIError
+	if opts.DryRun && (err == nil || errors.As(err, &apiErr) && apiErr.ErrorCode() == "DryRunOperation") {
+		return true, nil
+	}
+	if err != nil {
+		return true, fmt.Errorf("failed to %s instances %v: %w", action, instanceIDs, err)
+	}
+	return !opts.Wait, nil
+}
+
+func waitTimeout(opts LifecycleOptions) time.Duration {
+	if opts.WaitTimeout > 0 {
+		return opts.WaitTimeout
+	}
+	return defaultWaitTimeout
+}
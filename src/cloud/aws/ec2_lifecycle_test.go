@@ -0,0 +1,138 @@
+package aws_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/smithy-go"
+	ec2Client "github.com/onurcevik/deploy-utilities/src/cloud/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func instancesInState(state types.InstanceStateName, ids ...string) *ec2.DescribeInstancesOutput {
+	var instances []types.Instance
+	for _, id := range ids {
+		instances = append(instances, types.Instance{InstanceId: aws.String(id), State: &types.InstanceState{Name: state}})
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: []types.Reservation{{Instances: instances}}}
+}
+
+func TestStartInstancesWaits(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2}
+	ids := []string{"i-1", "i-2"}
+
+	mockEC2.On("StartInstances", mock.Anything, &ec2.StartInstancesInput{InstanceIds: ids, DryRun: aws.Bool(false)}, mock.Anything).
+		Return(&ec2.StartInstancesOutput{}, nil)
+	mockEC2.On("DescribeInstances", mock.Anything, &ec2.DescribeInstancesInput{InstanceIds: ids}, mock.Anything).
+		Return(instancesInState(types.InstanceStateNameRunning, ids...), nil)
+
+	require.NoError(t, client.StartInstances(ids, ec2Client.LifecycleOptions{Wait: true}))
+	mockEC2.AssertExpectations(t)
+}
+
+func TestStartInstancesWaitUsesPollInterval(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2, PollInterval: time.Millisecond}
+	ids := []string{"i-1"}
+
+	mockEC2.On("StartInstances", mock.Anything, mock.Anything, mock.Anything).Return(&ec2.StartInstancesOutput{}, nil)
+	mockEC2.On("DescribeInstances", mock.Anything, mock.Anything, mock.Anything).
+		Return(instancesInState(types.InstanceStateNamePending, ids...), nil).Once()
+	mockEC2.On("DescribeInstances", mock.Anything, mock.Anything, mock.Anything).
+		Return(instancesInState(types.InstanceStateNameRunning, ids...), nil).Once()
+
+	// The waiter would sleep 15 seconds between checks without PollInterval
+	started := time.Now()
+	require.NoError(t, client.StartInstances(ids, ec2Client.LifecycleOptions{Wait: true, WaitTimeout: time.Minute}))
+	assert.Less(t, time.Since(started), 5*time.Second)
+	mockEC2.AssertExpectations(t)
+}
+
+func TestStopInstancesWithoutWait(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2}
+	ids := []string{"i-1"}
+
+	mockEC2.On("StopInstances", mock.Anything, &ec2.StopInstancesInput{InstanceIds: ids, DryRun: aws.Bool(false), Force: aws.Bool(true)}, mock.Anything).
+		Return(&ec2.StopInstancesOutput{}, nil)
+
+	require.NoError(t, client.StopInstances(ids, ec2Client.LifecycleOptions{Force: true}))
+	mockEC2.AssertNotCalled(t, "DescribeInstances", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestTerminateInstancesWaitFailsOnUnexpectedState(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2}
+	ids := []string{"i-1"}
+
+	mockEC2.On("TerminateInstances", mock.Anything, mock.Anything, mock.Anything).Return(&ec2.TerminateInstancesOutput{}, nil)
+	// The terminated waiter fails right away when an instance is pending instead of shutting down
+	mockEC2.On("DescribeInstances", mock.Anything, mock.Anything, mock.Anything).
+		Return(instancesInState(types.InstanceStateNamePending, ids...), nil)
+
+	err := client.TerminateInstances(ids, ec2Client.LifecycleOptions{Wait: true})
+	assert.ErrorContains(t, err, "did not reach terminated state")
+}
+
+func TestRebootInstancesDryRun(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2}
+	ids := []string{"i-1"}
+
+	mockEC2.On("RebootInstances", mock.Anything, &ec2.RebootInstancesInput{InstanceIds: ids, DryRun: aws.Bool(true)}, mock.Anything).
+		Return(nil, &smithy.GenericAPIError{Code: "DryRunOperation", Message: "Request would have succeeded, but DryRun flag is set."})
+
+	require.NoError(t, client.RebootInstances(ids, ec2Client.LifecycleOptions{DryRun: true, Wait: true}))
+	mockEC2.AssertNotCalled(t, "DescribeInstanceStatus", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRebootInstancesDryRunUnauthorized(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2}
+
+	mockEC2.On("RebootInstances", mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, &smithy.GenericAPIError{Code: "UnauthorizedOperation", Message: "You are not authorized to perform this operation."})
+
+	err := client.RebootInstances([]string{"i-1"}, ec2Client.LifecycleOptions{DryRun: true})
+	assert.ErrorContains(t, err, "UnauthorizedOperation")
+}
+
+func instanceStatus(id string, status types.SummaryStatus) *ec2.DescribeInstanceStatusOutput {
+	return &ec2.DescribeInstanceStatusOutput{InstanceStatuses: []types.InstanceStatus{{
+		InstanceId:     aws.String(id),
+		InstanceState:  &types.InstanceState{Name: types.InstanceStateNameRunning},
+		InstanceStatus: &types.InstanceStatusSummary{Status: status},
+	}}}
+}
+
+func TestRebootInstancesWaitsForReboot(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2, PollInterval: time.Millisecond}
+	ids := []string{"i-1"}
+
+	mockEC2.On("RebootInstances", mock.Anything, mock.Anything, mock.Anything).Return(&ec2.RebootInstancesOutput{}, nil)
+	// Status checks are still ok right after the request, the wait must not return before they went through initializing
+	mockEC2.On("DescribeInstanceStatus", mock.Anything, mock.Anything, mock.Anything).Return(instanceStatus("i-1", types.SummaryStatusOk), nil).Twice()
+	mockEC2.On("DescribeInstanceStatus", mock.Anything, mock.Anything, mock.Anything).Return(instanceStatus("i-1", types.SummaryStatusInitializing), nil).Twice()
+	mockEC2.On("DescribeInstanceStatus", mock.Anything, mock.Anything, mock.Anything).Return(instanceStatus("i-1", types.SummaryStatusOk), nil).Once()
+
+	require.NoError(t, client.RebootInstances(ids, ec2Client.LifecycleOptions{Wait: true, WaitTimeout: time.Second}))
+	mockEC2.AssertExpectations(t)
+}
+
+func TestRebootInstancesNeverLeavesOk(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2, PollInterval: time.Millisecond}
+
+	mockEC2.On("RebootInstances", mock.Anything, mock.Anything, mock.Anything).Return(&ec2.RebootInstancesOutput{}, nil)
+	mockEC2.On("DescribeInstanceStatus", mock.Anything, mock.Anything, mock.Anything).Return(instanceStatus("i-1", types.SummaryStatusOk), nil)
+
+	err := client.RebootInstances([]string{"i-1"}, ec2Client.LifecycleOptions{Wait: true, WaitTimeout: 20 * time.Millisecond})
+	assert.ErrorContains(t, err, "instances [i-1] did not start rebooting")
+}
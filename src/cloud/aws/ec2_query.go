@@ -0,0 +1,205 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"time"
+)
+
+// InstanceQuery combines instance filters, every condition has to match while values given to the same call are alternatives
+// For example Query().State(types.InstanceStateNameRunning).Tag("env", "prod").Tag("role", "api") returns running prod api instances
+type InstanceQuery struct {
+	ec2            *EC2
+	ids            []string
+	filters        []types.Filter
+	launchedAfter  time.Time
+	launchedBefore time.Time
+}
+
+// Query starts a new instance query, without conditions it matches every instance in the region
+func (c *EC2) Query() *InstanceQuery {
+	return &InstanceQuery{ec2: c}
+}
+
+// IDs limits the query to the given instance IDs
+func (q *InstanceQuery) IDs(ids ...string) *InstanceQuery {
+	q.ids = append(q.ids, ids...)
+	return q
+}
+
+// Tag matches instances whose tag key has one of the given values
+func (q *InstanceQuery) Tag(key string, values ...string) *InstanceQuery {
+	return q.Filter("tag:"+key, values...)
+}
+
+// HasTag matches instances that have the tag key with any value
+func (q *InstanceQuery) HasTag(key string) *InstanceQuery {
+	return q.Filter("tag-key", key)
+}
+
+// State matches instances in one of the given states
+func (q *InstanceQuery) State(states ...types.InstanceStateName) *InstanceQuery {
+	values := make([]string, len(states))
+	for i, state := range states {
+		values[i] = string(state)
+	}
+	return q.Filter("instance-state-name", values...)
+}
+
+// Running is a shorthand for State(types.InstanceStateNameRunning)
+func (q *InstanceQuery) Running() *InstanceQuery {
+	return q.State(types.InstanceStateNameRunning)
+}
+
+// VPC matches instances in one of the given VPCs
+func (q *InstanceQuery) VPC(vpcIDs ...string) *InstanceQuery {
+	return q.Filter("vpc-id", vpcIDs...)
+}
+
+// Subnet matches instances in one of the given subnets
+func (q *InstanceQuery) Subnet(subnetIDs ...string) *InstanceQuery {
+	return q.Filter("subnet-id", subnetIDs...)
+}
+
+// AvailabilityZone matches instances in one of the given availability zones
+func (q *InstanceQuery) AvailabilityZone(zones ...string) *InstanceQuery {
+	return q.Filter("availability-zone", zones...)
+}
+
+// InstanceType matches instances of one of the given types
+func (q *InstanceQuery) InstanceType(instanceTypes ...types.InstanceType) *InstanceQuery {
+	values := make([]string, len(instanceTypes))
+	for i, instanceType := range instanceTypes {
+		values[i] = string(instanceType)
+	}
+	return q.Filter("instance-type", values...)
+}
+
+// LaunchedAfter matches instances launched after t, it is applied to the results since the EC2 launch-time filter has no ranges
+func (q *InstanceQuery) LaunchedAfter(t time.Time) *InstanceQuery {
+	q.launchedAfter = t
+	return q
+}
+
+// LaunchedBefore matches instances launched before t, it is applied to the results like LaunchedAfter
+func (q *InstanceQuery) LaunchedBefore(t time.Time) *InstanceQuery {
+	q.launchedBefore = t
+	return q
+}
+
+// Filter adds any DescribeInstances filter by name, such as private-dns-name or iam-instance-profile.arn
+func (q *InstanceQuery) Filter(name string, values ...string) *InstanceQuery {
+	q.filters = append(q.filters, types.Filter{Name: aws.String(name), Values: values})
+	return q
+}
+
+// Input returns the DescribeInstances input the query sends
+func (q *InstanceQuery) Input() *ec2.DescribeInstancesInput {
+	input := &ec2.DescribeInstancesInput{}
+	if len(q.ids) > 0 {
+		input.InstanceIds = append([]string(nil), q.ids...)
+	}
+	if len(q.filters) > 0 {
+		input.Filters = append([]types.Filter(nil), q.filters...)
+	}
+	return input
+}
+
+// All returns every matching instance across all pages
+func (q *InstanceQuery) All() ([]types.Instance, error) {
+	var instances []types.Instance
+	it := q.Iter()
+	for it.Next() {
+		instances = append(instances, it.Instance())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// First returns the first matching instance
+func (q *InstanceQuery) First() (*types.Instance, error) {
+	it := q.Iter()
+	if it.Next() {
+		instance := it.Instance()
+		return &instance, nil
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no instances match the query")
+}
+
+// Iter returns an iterator that requests pages as they are consumed, useful to stop early on large fleets
+func (q *InstanceQuery) Iter() *InstanceIterator {
+	return &InstanceIterator{query: q, paginator: q.ec2.instancePaginator(q.Input())}
+}
+
+// matches applies the conditions EC2 can't filter on
+func (q *InstanceQuery) matches(instance types.Instance) bool {
+	if instance.LaunchTime == nil {
+		return q.launchedAfter.IsZero() && q.launchedBefore.IsZero()
+	}
+	if !q.launchedAfter.IsZero() && !instance.LaunchTime.After(q.launchedAfter) {
+		return false
+	}
+	if !q.launchedBefore.IsZero() && !instance.LaunchTime.Before(q.launchedBefore) {
+		return false
+	}
+	return true
+}
+
+// InstanceIterator walks the instances of a query page by page
+//
+//	it := client.Query().Running().Tag("env", "prod").Iter()
+//	for it.Next() {
+//		instance := it.Instance()
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+type InstanceIterator struct {
+	query     *InstanceQuery
+	paginator *ec2.DescribeInstancesPaginator
+	page      []types.Instance
+	current   types.Instance
+	err       error
+}
+
+// Next advances to the next instance, it returns false when there are no more instances or a page request failed
+func (it *InstanceIterator) Next() bool {
+	for {
+		for len(it.page) > 0 {
+			it.current, it.page = it.page[0], it.page[1:]
+			if it.query.matches(it.current) {
+				return true
+			}
+		}
+		if it.err != nil || !it.paginator.HasMorePages() {
+			return false
+		}
+
+		page, err := it.paginator.NextPage(context.TODO())
+		if err != nil {
+			it.err = fmt.Errorf("failed to describe instances: %w", err)
+			return false
+		}
+		for _, reservation := range page.Reservations {
+			it.page = append(it.page, reservation.Instances...)
+		}
+	}
+}
+
+// Instance returns the instance Next moved to
+func (it *InstanceIterator) Instance() types.Instance {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *InstanceIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,88 @@
+package aws_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ec2Client "github.com/onurcevik/deploy-utilities/src/cloud/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceQueryInput(t *testing.T) {
+	client := &ec2Client.EC2{Client: new(MockEC2API)}
+
+	input := client.Query().
+		IDs("i-1", "i-2").
+		Running().
+		Tag("env", "prod").
+		Tag("role", "api", "worker").
+		VPC("vpc-1").
+		Subnet("subnet-a", "subnet-b").
+		AvailabilityZone("eu-west-1a").
+		InstanceType(types.InstanceTypeT3Micro).
+		Input()
+
+	assert.Equal(t, []string{"i-1", "i-2"}, input.InstanceIds)
+	assert.Equal(t, []types.Filter{
+		{Name: aws.String("instance-state-name"), Values: []string{"running"}},
+		{Name: aws.String("tag:env"), Values: []string{"prod"}},
+		{Name: aws.String("tag:role"), Values: []string{"api", "worker"}},
+		{Name: aws.String("vpc-id"), Values: []string{"vpc-1"}},
+		{Name: aws.String("subnet-id"), Values: []string{"subnet-a", "subnet-b"}},
+		{Name: aws.String("availability-zone"), Values: []string{"eu-west-1a"}},
+		{Name: aws.String("instance-type"), Values: []string{"t3.micro"}},
+	}, input.Filters)
+}
+
+func TestInstanceQueryAll(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2}
+	launched := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	mockEC2.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+		return input.NextToken == nil && len(input.Filters) == 2
+	}), mock.Anything).Return(&ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{
+			{InstanceId: aws.String("i-old"), LaunchTime: aws.Time(launched.Add(-48 * time.Hour))},
+			{InstanceId: aws.String("i-1"), LaunchTime: aws.Time(launched.Add(time.Hour))},
+		}}},
+		NextToken: aws.String("page-2"),
+	}, nil).Once()
+	mockEC2.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+		return aws.ToString(input.NextToken) == "page-2"
+	}), mock.Anything).Return(&ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{
+			{InstanceId: aws.String("i-2"), LaunchTime: aws.Time(launched.Add(2 * time.Hour))},
+		}}},
+	}, nil).Once()
+
+	instances, err := client.Query().Running().Tag("env", "prod").LaunchedAfter(launched).All()
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+	assert.Equal(t, "i-1", *instances[0].InstanceId)
+	assert.Equal(t, "i-2", *instances[1].InstanceId)
+
+	mockEC2.AssertExpectations(t)
+}
+
+func TestInstanceIteratorStopsEarly(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2}
+
+	mockEC2.On("DescribeInstances", mock.Anything, mock.Anything, mock.Anything).Return(&ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{{InstanceId: aws.String("i-1")}}}},
+		NextToken:    aws.String("page-2"),
+	}, nil).Once()
+
+	instance, err := client.Query().Tag("role", "api").First()
+	require.NoError(t, err)
+	assert.Equal(t, "i-1", *instance.InstanceId)
+
+	// The second page is never requested
+	mockEC2.AssertNumberOfCalls(t, "DescribeInstances", 1)
+}
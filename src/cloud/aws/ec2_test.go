@@ -2,6 +2,7 @@ package aws_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -27,6 +28,69 @@ func (m *MockEC2API) DescribeInstances(ctx context.Context, params *ec2.Describe
 	return output, args.Error(1)
 }
 
+func (m *MockEC2API) DescribeInstanceStatus(ctx context.Context, params *ec2.DescribeInstanceStatusInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstanceStatusOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*ec2.DescribeInstanceStatusOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockEC2API) StartInstances(ctx context.Context, params *ec2.StartInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*ec2.StartInstancesOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockEC2API) StopInstances(ctx context.Context, params *ec2.StopInstancesInput, optFns ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*ec2.StopInstancesOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockEC2API) RebootInstances(ctx context.Context, params *ec2.RebootInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RebootInstancesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*ec2.RebootInstancesOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockEC2API) TerminateInstances(ctx context.Context, params *ec2.TerminateInstancesInput, optFns ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*ec2.TerminateInstancesOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockEC2API) RunInstances(ctx context.Context, params *ec2.RunInstancesInput, optFns ...func(*ec2.Options)) (*ec2.RunInstancesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*ec2.RunInstancesOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockEC2API) DescribeImages(ctx context.Context, params *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*ec2.DescribeImagesOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
 func TestGetInstanceByID(t *testing.T) {
 	mockEC2 := new(MockEC2API)
 	client := &ec2Client.EC2{Client: mockEC2}
@@ -115,3 +179,81 @@ func TestGetInstancesByTag(t *testing.T) {
 
 	mockEC2.AssertExpectations(t)
 }
+
+func TestGetInstancesByTagPaginates(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2, PageSize: 5}
+
+	firstPage := mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+		return input.NextToken == nil && aws.ToInt32(input.MaxResults) == 5
+	})
+	secondPage := mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+		return aws.ToString(input.NextToken) == "page-2" && aws.ToInt32(input.MaxResults) == 5
+	})
+	mockEC2.On("DescribeInstances", mock.Anything, firstPage, mock.Anything).Return(&ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{Instances: []types.Instance{{InstanceId: aws.String("i-1")}, {InstanceId: aws.String("i-2")}}},
+			{Instances: []types.Instance{{InstanceId: aws.String("i-3")}}},
+		},
+		NextToken: aws.String("page-2"),
+	}, nil).Once()
+	mockEC2.On("DescribeInstances", mock.Anything, secondPage, mock.Anything).Return(&ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{
+			{Instances: []types.Instance{{InstanceId: aws.String("i-4")}}},
+		},
+	}, nil).Once()
+
+	instances, err := client.GetInstancesByTag("env", "prod")
+	assert.NoError(t, err)
+	assert.Len(t, instances, 4)
+	assert.Equal(t, "i-4", *instances[3].InstanceId)
+
+	mockEC2.AssertExpectations(t)
+}
+
+func TestGetInstancesByTagClampsPageSize(t *testing.T) {
+	for pageSize, expected := range map[int32]int32{2: 5, 5000: 1000, -1: 0} {
+		mockEC2 := new(MockEC2API)
+		client := &ec2Client.EC2{Client: mockEC2, PageSize: pageSize}
+
+		mockEC2.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+			return aws.ToInt32(input.MaxResults) == expected
+		}), mock.Anything).Return(&ec2.DescribeInstancesOutput{
+			Reservations: []types.Reservation{{Instances: []types.Instance{{InstanceId: aws.String("i-1")}}}},
+		}, nil).Once()
+
+		_, err := client.GetInstancesByTag("env", "prod")
+		assert.NoError(t, err, "page size %d", pageSize)
+		mockEC2.AssertExpectations(t)
+	}
+}
+
+func TestGetInstanceByIDDoesNotSetPageSize(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2, PageSize: 5}
+
+	mockEC2.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+		return input.MaxResults == nil
+	}), mock.Anything).Return(&ec2.DescribeInstancesOutput{}, nil)
+
+	_, err := client.GetInstanceByID("i-123456")
+	assert.ErrorContains(t, err, "instance not found with ID i-123456")
+}
+
+func TestGetInstancesByTagPageError(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2}
+
+	mockEC2.On("DescribeInstances", mock.Anything, mock.MatchedBy(func(input *ec2.DescribeInstancesInput) bool {
+		return input.NextToken == nil
+	}), mock.Anything).Return(&ec2.DescribeInstancesOutput{
+		Reservations: []types.Reservation{{Instances: []types.Instance{{InstanceId: aws.String("i-1")}}}},
+		NextToken:    aws.String("page-2"),
+	}, nil).Once()
+	mockEC2.On("DescribeInstances", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("throttled")).Once()
+
+	// A failed page must not return a silently truncated result
+	instances, err := client.GetInstancesByTag("env", "prod")
+	assert.ErrorContains(t, err, "throttled")
+	assert.Nil(t, instances)
+}
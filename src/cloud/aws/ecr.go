@@ -0,0 +1,116 @@
+package aws
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/onurcevik/deploy-utilities/src/docker"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryMargin makes cached tokens renew slightly before they expire so long pulls don't fail halfway
+const tokenExpiryMargin = 5 * time.Minute
+
+// ECRAPI interface added in order to make mock testing easier, same as EC2API
+type ECRAPI interface {
+	GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error)
+}
+
+// ECR struct fetches registry authorization tokens and caches them until they expire
+type ECR struct {
+	Client ECRAPI
+
+	mu    sync.Mutex
+	token *ECRToken
+}
+
+// ECRToken holds decoded docker login credentials for the ECR registry
+type ECRToken struct {
+	Username      string
+	Password      string
+	ProxyEndpoint string
+	ExpiresAt     time.Time
+}
+
+// NewECR initializes new ecr client to use
+func NewECR(ctx context.Context, logger slog.Logger, accessKey, secretAccessKey, session string) *ECR {
+	cfg := loadConfig(ctx, logger, accessKey, secretAccessKey, session)
+	return &ECR{Client: ecr.NewFromConfig(cfg)}
+}
+
+// GetAuthorizationToken returns the cached token or requests a new one from ECR once the cached one is about to expire
+func (c *ECR) GetAuthorizationToken() (*ECRToken, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != nil && time.Now().Add(tokenExpiryMargin).Before(c.token.ExpiresAt) {
+		return c.token, nil
+	}
+
+	result, err := c.Client.GetAuthorizationToken(context.TODO(), &ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ecr authorization token: %w", err)
+	}
+
+	if len(result.AuthorizationData) == 0 || result.AuthorizationData[0].AuthorizationToken == nil {
+		return nil, fmt.Errorf("ecr returned no authorization data")
+	}
+	data := result.AuthorizationData[0]
+
+	decoded, err := base64.StdEncoding.DecodeString(*data.AuthorizationToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ecr authorization token: %w", err)
+	}
+	user, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return nil, fmt.Errorf("ecr authorization token is not in user:password format")
+	}
+
+	token := &ECRToken{
+		Username: user,
+		Password: password,
+	}
+	if data.ProxyEndpoint != nil {
+		token.ProxyEndpoint = *data.ProxyEndpoint
+	}
+	if data.ExpiresAt != nil {
+		token.ExpiresAt = *data.ExpiresAt
+	}
+	c.token = token
+
+	return token, nil
+}
+
+// LoginDocker logs the Docker object in to the ECR registry so PullDockerImage and PushDockerImage can use ECR images
+// The Docker credential store asks ECR for the credentials on every use, so they are renewed before the token expires
+func (c *ECR) LoginDocker(d *docker.Docker) error {
+	token, err := c.GetAuthorizationToken()
+	if err != nil {
+		return err
+	}
+
+	if err := d.LoginDocker(token.Username, token.Password, token.ProxyEndpoint); err != nil {
+		return fmt.Errorf("failed to login to ecr registry %s: %w", token.ProxyEndpoint, err)
+	}
+	d.Credentials.SetProvider(token.ProxyEndpoint, c.registryAuth)
+
+	return nil
+}
+
+// registryAuth returns the docker credentials of the cached token, renewing it when it is about to expire
+func (c *ECR) registryAuth() (registry.AuthConfig, error) {
+	token, err := c.GetAuthorizationToken()
+	if err != nil {
+		return registry.AuthConfig{}, err
+	}
+	return registry.AuthConfig{
+		Username:      token.Username,
+		Password:      token.Password,
+		ServerAddress: token.ProxyEndpoint,
+	}, nil
+}
@@ -0,0 +1,145 @@
+package aws_test
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/client"
+	ecrClient "github.com/onurcevik/deploy-utilities/src/cloud/aws"
+	"github.com/onurcevik/deploy-utilities/src/docker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockECRAPI is a mock type for the ECRAPI interface
+type MockECRAPI struct {
+	mock.Mock
+}
+
+// GetAuthorizationToken provides a mock function with given fields: ctx, params, optFns
+func (m *MockECRAPI) GetAuthorizationToken(ctx context.Context, params *ecr.GetAuthorizationTokenInput, optFns ...func(*ecr.Options)) (*ecr.GetAuthorizationTokenOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*ecr.GetAuthorizationTokenOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+// MockDockerClient is a mock implementation of the Docker client login
+type MockDockerClient struct {
+	client.APIClient
+	mock.Mock
+}
+
+func (m *MockDockerClient) RegistryLogin(ctx context.Context, auth registry.AuthConfig) (registry.AuthenticateOKBody, error) {
+	args := m.Called(ctx, auth)
+	return args.Get(0).(registry.AuthenticateOKBody), args.Error(1)
+}
+
+func authorizationOutput(expiresAt time.Time) *ecr.GetAuthorizationTokenOutput {
+	return &ecr.GetAuthorizationTokenOutput{
+		AuthorizationData: []types.AuthorizationData{
+			{
+				AuthorizationToken: aws.String(base64.StdEncoding.EncodeToString([]byte("AWS:secret-token"))),
+				ProxyEndpoint:      aws.String("https://123456789012.dkr.ecr.eu-west-1.amazonaws.com"),
+				ExpiresAt:          aws.Time(expiresAt),
+			},
+		},
+	}
+}
+
+func TestGetAuthorizationTokenIsCached(t *testing.T) {
+	mockECR := new(MockECRAPI)
+	client := &ecrClient.ECR{Client: mockECR}
+
+	mockECR.On("GetAuthorizationToken", mock.Anything, mock.Anything, mock.Anything).
+		Return(authorizationOutput(time.Now().Add(12*time.Hour)), nil).Once()
+
+	token, err := client.GetAuthorizationToken()
+	require.NoError(t, err)
+	assert.Equal(t, "AWS", token.Username)
+	assert.Equal(t, "secret-token", token.Password)
+
+	cached, err := client.GetAuthorizationToken()
+	require.NoError(t, err)
+	assert.Same(t, token, cached)
+
+	mockECR.AssertExpectations(t)
+}
+
+func TestGetAuthorizationTokenRenewsExpiredToken(t *testing.T) {
+	mockECR := new(MockECRAPI)
+	client := &ecrClient.ECR{Client: mockECR}
+
+	mockECR.On("GetAuthorizationToken", mock.Anything, mock.Anything, mock.Anything).
+		Return(authorizationOutput(time.Now().Add(time.Minute)), nil).Twice()
+
+	_, err := client.GetAuthorizationToken()
+	require.NoError(t, err)
+	_, err = client.GetAuthorizationToken()
+	require.NoError(t, err)
+
+	mockECR.AssertExpectations(t)
+}
+
+func TestECRLoginDocker(t *testing.T) {
+	mockECR := new(MockECRAPI)
+	mockDocker := new(MockDockerClient)
+	client := &ecrClient.ECR{Client: mockECR}
+	d := &docker.Docker{Client: mockDocker}
+
+	mockECR.On("GetAuthorizationToken", mock.Anything, mock.Anything, mock.Anything).
+		Return(authorizationOutput(time.Now().Add(12*time.Hour)), nil)
+	mockDocker.On("RegistryLogin", mock.Anything, registry.AuthConfig{
+		Username:      "AWS",
+		Password:      "secret-token",
+		ServerAddress: "https://123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+	}).Return(registry.AuthenticateOKBody{}, nil)
+
+	require.NoError(t, client.LoginDocker(d))
+
+	auth, ok, err := d.Credentials.ForImage("123456789012.dkr.ecr.eu-west-1.amazonaws.com/api:1.0")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "secret-token", auth.Password)
+
+	mockECR.AssertExpectations(t)
+	mockDocker.AssertExpectations(t)
+}
+
+func TestECRLoginDockerRenewsExpiredCredentials(t *testing.T) {
+	mockECR := new(MockECRAPI)
+	mockDocker := new(MockDockerClient)
+	client := &ecrClient.ECR{Client: mockECR}
+	d := &docker.Docker{Client: mockDocker}
+
+	renewed := authorizationOutput(time.Now().Add(12 * time.Hour))
+	renewed.AuthorizationData[0].AuthorizationToken = aws.String(base64.StdEncoding.EncodeToString([]byte("AWS:renewed-token")))
+	mockECR.On("GetAuthorizationToken", mock.Anything, mock.Anything, mock.Anything).
+		Return(authorizationOutput(time.Now().Add(time.Minute)), nil).Once()
+	mockECR.On("GetAuthorizationToken", mock.Anything, mock.Anything, mock.Anything).Return(renewed, nil).Once()
+	mockDocker.On("RegistryLogin", mock.Anything, mock.Anything).Return(registry.AuthenticateOKBody{}, nil)
+
+	require.NoError(t, client.LoginDocker(d))
+
+	// The first token expires within the renewal margin, so the store gets the renewed one
+	auth, ok, err := d.Credentials.ForImage("123456789012.dkr.ecr.eu-west-1.amazonaws.com/api:1.0")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "renewed-token", auth.Password)
+
+	// The renewed token is cached until it is about to expire
+	auth, _, err = d.Credentials.ForImage("123456789012.dkr.ecr.eu-west-1.amazonaws.com/worker:1.0")
+	require.NoError(t, err)
+	assert.Equal(t, "renewed-token", auth.Password)
+
+	mockECR.AssertExpectations(t)
+}
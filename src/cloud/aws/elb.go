@@ -0,0 +1,168 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultDeregistrationDelay is the AWS default connection draining time, used when the target group attribute can't be read
+	defaultDeregistrationDelay = 300 * time.Second
+	// drainMargin is added to the deregistration delay since targets stay draining slightly longer than the delay
+	drainMargin = time.Minute
+	// defaultHealthyTimeout is how long RegisterTarget waits for a target to become healthy
+	defaultHealthyTimeout = 10 * time.Minute
+)
+
+// ELBAPI interface added in order to make mock testing easier, same as EC2API
+type ELBAPI interface {
+	RegisterTargets(ctx context.Context, params *elbv2.RegisterTargetsInput, optFns ...func(*elbv2.Options)) (*elbv2.RegisterTargetsOutput, error)
+	DeregisterTargets(ctx context.Context, params *elbv2.DeregisterTargetsInput, optFns ...func(*elbv2.Options)) (*elbv2.DeregisterTargetsOutput, error)
+	DescribeTargetHealth(ctx context.Context, params *elbv2.DescribeTargetHealthInput, optFns ...func(*elbv2.Options)) (*elbv2.DescribeTargetHealthOutput, error)
+	DescribeTargetGroupAttributes(ctx context.Context, params *elbv2.DescribeTargetGroupAttributesInput, optFns ...func(*elbv2.Options)) (*elbv2.DescribeTargetGroupAttributesOutput, error)
+}
+
+// ELB struct manages target group registration, PollInterval overrides the 15 second AWS waiter delay when set
+type ELB struct {
+	Client       ELBAPI
+	PollInterval time.Duration
+	// HealthyTimeout is how long RegisterTarget waits for the target to become healthy, defaults to 10 minutes
+	HealthyTimeout time.Duration
+}
+
+// Target is an instance in a target group, Port is only needed when the group doesn't use the default port of the instance
+type Target struct {
+	TargetGroupARN string
+	InstanceID     string
+	Port           int32
+}
+
+func (t Target) description() []types.TargetDescription {
+	target := types.TargetDescription{Id: aws.String(t.InstanceID)}
+	if t.Port > 0 {
+		target.Port = aws.Int32(t.Port)
+	}
+	return []types.TargetDescription{target}
+}
+
+// NewELB initializes new elbv2 client to use
+func NewELB(ctx context.Context, logger slog.Logger, accessKey, secretAccessKey, session string) *ELB {
+	cfg := loadConfig(ctx, logger, accessKey, secretAccessKey, session)
+	return &ELB{Client: elbv2.NewFromConfig(cfg)}
+}
+
+// TargetHealth returns the health state of a target such as healthy, unhealthy, draining or unused
+func (c *ELB) TargetHealth(target Target) (types.TargetHealthStateEnum, error) {
+	result, err := c.Client.DescribeTargetHealth(context.TODO(), &elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: aws.String(target.TargetGroupARN),
+		Targets:        target.description(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe health of %s in %s: %w", target.InstanceID, target.TargetGroupARN, err)
+	}
+	if len(result.TargetHealthDescriptions) == 0 || result.TargetHealthDescriptions[0].TargetHealth == nil {
+		return types.TargetHealthStateEnumUnused, nil
+	}
+	return result.TargetHealthDescriptions[0].TargetHealth.State, nil
+}
+
+// DeregisterTarget removes a target from its group, with wait it returns once connection draining finished
+func (c *ELB) DeregisterTarget(target Target, wait bool) error {
+	_, err := c.Client.DeregisterTargets(context.TODO(), &elbv2.DeregisterTargetsInput{
+		TargetGroupArn: aws.String(target.TargetGroupARN),
+		Targets:        target.description(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to deregister %s from %s: %w", target.InstanceID, target.TargetGroupARN, err)
+	}
+	if !wait {
+		return nil
+	}
+
+	timeout := c.deregistrationDelay(target.TargetGroupARN) + drainMargin
+	err = elbv2.NewTargetDeregisteredWaiter(c.Client, func(o *elbv2.TargetDeregisteredWaiterOptions) {
+		if c.PollInterval > 0 {
+			o.MinDelay, o.MaxDelay = c.PollInterval, c.PollInterval
+		}
+	}).Wait(context.TODO(), &elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: aws.String(target.TargetGroupARN),
+		Targets:        target.description(),
+	}, timeout)
+	if err != nil {
+		return fmt.Errorf("%s did not finish draining from %s: %w", target.InstanceID, target.TargetGroupARN, err)
+	}
+	return nil
+}
+
+// RegisterTarget adds a target to its group, with wait it returns once the target passes the health checks
+func (c *ELB) RegisterTarget(target Target, wait bool) error {
+	_, err := c.Client.RegisterTargets(context.TODO(), &elbv2.RegisterTargetsInput{
+		TargetGroupArn: aws.String(target.TargetGroupARN),
+		Targets:        target.description(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to register %s in %s: %w", target.InstanceID, target.TargetGroupARN, err)
+	}
+	if !wait {
+		return nil
+	}
+	return c.WaitForHealthy(target)
+}
+
+// WaitForHealthy blocks until the target is healthy or HealthyTimeout passes
+func (c *ELB) WaitForHealthy(target Target) error {
+	timeout := c.HealthyTimeout
+	if timeout <= 0 {
+		timeout = defaultHealthyTimeout
+	}
+
+	err := elbv2.NewTargetInServiceWaiter(c.Client, func(o *elbv2.TargetInServiceWaiterOptions) {
+		if c.PollInterval > 0 {
+			o.MinDelay, o.MaxDelay = c.PollInterval, c.PollInterval
+		}
+	}).Wait(context.TODO(), &elbv2.DescribeTargetHealthInput{
+		TargetGroupArn: aws.String(target.TargetGroupARN),
+		Targets:        target.description(),
+	}, timeout)
+	if err != nil {
+		return fmt.Errorf("%s did not become healthy in %s: %w", target.InstanceID, target.TargetGroupARN, err)
+	}
+	return nil
+}
+
+// WithTargetDrained takes a target out of its group, runs deploy and puts the target back once deploy succeeded
+// The target is only registered again when deploy returns nil, a failed host stays out of rotation for inspection
+func (c *ELB) WithTargetDrained(target Target, deploy func() error) error {
+	if err := c.DeregisterTarget(target, true); err != nil {
+		return err
+	}
+	if err := deploy(); err != nil {
+		return fmt.Errorf("deploy on %s failed, it stays deregistered from %s: %w", target.InstanceID, target.TargetGroupARN, err)
+	}
+	return c.RegisterTarget(target, true)
+}
+
+// deregistrationDelay reads the connection draining time of a target group
+func (c *ELB) deregistrationDelay(targetGroupARN string) time.Duration {
+	result, err := c.Client.DescribeTargetGroupAttributes(context.TODO(), &elbv2.DescribeTargetGroupAttributesInput{
+		TargetGroupArn: aws.String(targetGroupARN),
+	})
+	if err != nil {
+		return defaultDeregistrationDelay
+	}
+	for _, attribute := range result.Attributes {
+		if aws.ToString(attribute.Key) != "deregistration_delay.timeout_seconds" {
+			continue
+		}
+		if seconds, err := strconv.Atoi(aws.ToString(attribute.Value)); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return defaultDeregistrationDelay
+}
@@ -0,0 +1,134 @@
+package aws_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	elbv2 "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	elbClient "github.com/onurcevik/deploy-utilities/src/cloud/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockELBAPI is a mock type for the ELBAPI interface
+type MockELBAPI struct {
+	mock.Mock
+}
+
+func (m *MockELBAPI) RegisterTargets(ctx context.Context, params *elbv2.RegisterTargetsInput, optFns ...func(*elbv2.Options)) (*elbv2.RegisterTargetsOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*elbv2.RegisterTargetsOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockELBAPI) DeregisterTargets(ctx context.Context, params *elbv2.DeregisterTargetsInput, optFns ...func(*elbv2.Options)) (*elbv2.DeregisterTargetsOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*elbv2.DeregisterTargetsOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockELBAPI) DescribeTargetHealth(ctx context.Context, params *elbv2.DescribeTargetHealthInput, optFns ...func(*elbv2.Options)) (*elbv2.DescribeTargetHealthOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*elbv2.DescribeTargetHealthOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockELBAPI) DescribeTargetGroupAttributes(ctx context.Context, params *elbv2.DescribeTargetGroupAttributesInput, optFns ...func(*elbv2.Options)) (*elbv2.DescribeTargetGroupAttributesOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*elbv2.DescribeTargetGroupAttributesOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+const targetGroupARN = "arn:aws:elasticloadbalancing:eu-west-1:123456789012:targetgroup/api/abc"
+
+func targetHealth(state types.TargetHealthStateEnum) *elbv2.DescribeTargetHealthOutput {
+	return &elbv2.DescribeTargetHealthOutput{TargetHealthDescriptions: []types.TargetHealthDescription{{
+		Target:       &types.TargetDescription{Id: aws.String("i-1")},
+		TargetHealth: &types.TargetHealth{State: state},
+	}}}
+}
+
+func TestWithTargetDrained(t *testing.T) {
+	mockELB := new(MockELBAPI)
+	client := &elbClient.ELB{Client: mockELB, PollInterval: time.Millisecond}
+	target := elbClient.Target{TargetGroupARN: targetGroupARN, InstanceID: "i-1", Port: 8080}
+
+	mockELB.On("DeregisterTargets", mock.Anything, &elbv2.DeregisterTargetsInput{
+		TargetGroupArn: aws.String(targetGroupARN),
+		Targets:        []types.TargetDescription{{Id: aws.String("i-1"), Port: aws.Int32(8080)}},
+	}, mock.Anything).Return(&elbv2.DeregisterTargetsOutput{}, nil)
+	mockELB.On("DescribeTargetGroupAttributes", mock.Anything, mock.Anything, mock.Anything).Return(&elbv2.DescribeTargetGroupAttributesOutput{
+		Attributes: []types.TargetGroupAttribute{{Key: aws.String("deregistration_delay.timeout_seconds"), Value: aws.String("30")}},
+	}, nil)
+	mockELB.On("DescribeTargetHealth", mock.Anything, mock.Anything, mock.Anything).Return(targetHealth(types.TargetHealthStateEnumDraining), nil).Once()
+	mockELB.On("DescribeTargetHealth", mock.Anything, mock.Anything, mock.Anything).Return(targetHealth(types.TargetHealthStateEnumUnused), nil).Once()
+	mockELB.On("RegisterTargets", mock.Anything, mock.Anything, mock.Anything).Return(&elbv2.RegisterTargetsOutput{}, nil)
+	mockELB.On("DescribeTargetHealth", mock.Anything, mock.Anything, mock.Anything).Return(targetHealth(types.TargetHealthStateEnumInitial), nil).Once()
+	mockELB.On("DescribeTargetHealth", mock.Anything, mock.Anything, mock.Anything).Return(targetHealth(types.TargetHealthStateEnumHealthy), nil).Once()
+
+	deployed := false
+	err := client.WithTargetDrained(target, func() error {
+		// The deploy runs once the target was deregistered and drained, and before it is registered again
+		deployed = true
+		mockELB.AssertCalled(t, "DeregisterTargets", mock.Anything, mock.Anything, mock.Anything)
+		mockELB.AssertNumberOfCalls(t, "DescribeTargetHealth", 2)
+		mockELB.AssertNotCalled(t, "RegisterTargets", mock.Anything, mock.Anything, mock.Anything)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, deployed)
+	mockELB.AssertExpectations(t)
+}
+
+func TestWithTargetDrainedKeepsFailedTargetOut(t *testing.T) {
+	mockELB := new(MockELBAPI)
+	client := &elbClient.ELB{Client: mockELB, PollInterval: time.Millisecond}
+	target := elbClient.Target{TargetGroupARN: targetGroupARN, InstanceID: "i-1"}
+
+	mockELB.On("DeregisterTargets", mock.Anything, mock.Anything, mock.Anything).Return(&elbv2.DeregisterTargetsOutput{}, nil)
+	mockELB.On("DescribeTargetGroupAttributes", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("access denied"))
+	mockELB.On("DescribeTargetHealth", mock.Anything, mock.Anything, mock.Anything).Return(targetHealth(types.TargetHealthStateEnumUnused), nil)
+
+	err := client.WithTargetDrained(target, func() error { return errors.New("container did not start") })
+	assert.ErrorContains(t, err, "stays deregistered")
+	assert.ErrorContains(t, err, "container did not start")
+	mockELB.AssertNotCalled(t, "RegisterTargets", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWaitForHealthyTimesOut(t *testing.T) {
+	mockELB := new(MockELBAPI)
+	client := &elbClient.ELB{Client: mockELB, PollInterval: time.Millisecond, HealthyTimeout: 20 * time.Millisecond}
+
+	mockELB.On("DescribeTargetHealth", mock.Anything, mock.Anything, mock.Anything).Return(targetHealth(types.TargetHealthStateEnumUnhealthy), nil)
+
+	err := client.WaitForHealthy(elbClient.Target{TargetGroupARN: targetGroupARN, InstanceID: "i-1"})
+	assert.ErrorContains(t, err, "i-1 did not become healthy")
+}
+
+func TestTargetHealth(t *testing.T) {
+	mockELB := new(MockELBAPI)
+	client := &elbClient.ELB{Client: mockELB}
+
+	mockELB.On("DescribeTargetHealth", mock.Anything, mock.Anything, mock.Anything).Return(targetHealth(types.TargetHealthStateEnumDraining), nil)
+
+	state, err := client.TargetHealth(elbClient.Target{TargetGroupARN: targetGroupARN, InstanceID: "i-1"})
+	require.NoError(t, err)
+	assert.Equal(t, types.TargetHealthStateEnumDraining, state)
+}
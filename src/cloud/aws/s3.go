@@ -0,0 +1,281 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/onurcevik/deploy-utilities/src/utils"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPartSize is the multipart part size when PartSize is zero, files up to one part are uploaded with a single PutObject
+	defaultPartSize = 64 << 20
+	// minPartSize is the smallest part S3 accepts except for the last one
+	minPartSize = 5 << 20
+	// checksumMetadata is the object metadata key holding the sha256 of the artifact
+	checksumMetadata = "sha256"
+)
+
+// S3API interface added in order to make mock testing easier, same as EC2API
+type S3API interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+// S3PresignAPI is the part of s3.PresignClient used to generate download URLs
+type S3PresignAPI interface {
+	PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+// S3 struct manages build artifacts, PartSize defaults to 64MiB and can't be lower than the 5MiB S3 minimum
+type S3 struct {
+	Client    S3API
+	Presigner S3PresignAPI
+	PartSize  int64
+}
+
+// Artifact is an object in S3, Checksum is the hex sha256 of its content stored in the object metadata on upload
+type Artifact struct {
+	Bucket   string
+	Key      string
+	Size     int64
+	Checksum string
+	ETag     string
+}
+
+// NewS3 initializes new s3 client to use
+func NewS3(ctx context.Context, logger slog.Logger, accessKey, secretAccessKey, session string) *S3 {
+	cfg := loadConfig(ctx, logger, accessKey, secretAccessKey, session)
+	client := s3.NewFromConfig(cfg)
+	return &S3{Client: client, Presigner: s3.NewPresignClient(client)}
+}
+
+// UploadArtifact uploads a local file to bucket/key with its sha256 checksum, files larger than PartSize are uploaded in parts
+// A failed multipart upload is aborted so the parts already uploaded are not billed
+func (c *S3) UploadArtifact(path, bucket, key string) (Artifact, error) {
+	artifact := Artifact{Bucket: bucket, Key: key}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return artifact, fmt.Errorf("could not open artifact %s: %w", path, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return artifact, fmt.Errorf("could not stat artifact %s: %w", path, err)
+	}
+	artifact.Size = info.Size()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return artifact, fmt.Errorf("could not read artifact %s: %w", path, err)
+	}
+	artifact.Checksum = hex.EncodeToString(hash.Sum(nil))
+	metadata := map[string]string{checksumMetadata: artifact.Checksum}
+
+	partSize := c.partSize()
+	if artifact.Size <= partSize {
+		result, err := c.Client.PutObject(context.TODO(), &s3.PutObjectInput{
+			Bucket:        aws.String(bucket),
+			Key:           aws.String(key),
+			Body:          io.NewSectionReader(f, 0, artifact.Size),
+			ContentLength: aws.Int64(artifact.Size),
+			Metadata:      metadata,
+		})
+		if err != nil {
+			return artifact, fmt.Errorf("failed to upload %s to s3://%s/%s: %w", path, bucket, key, err)
+		}
+		artifact.ETag = aws.ToString(result.ETag)
+		return artifact, nil
+	}
+
+	etag, err := c.multipartUpload(f, artifact.Size, partSize, bucket, key, metadata)
+	if err != nil {
+		return artifact, fmt.Errorf("failed to upload %s to s3://%s/%s: %w", path, bucket, key, err)
+	}
+	artifact.ETag = etag
+	return artifact, nil
+}
+
+// multipartUpload uploads f in parts of partSize and returns the ETag of the completed object
+func (c *S3) multipartUpload(f *os.File, size, partSize int64, bucket, key string, metadata map[string]string) (string, error) {
+	created, err := c.Client.CreateMultipartUpload(context.TODO(), &s3.CreateMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		Metadata: metadata,
+	})
+	if err != nil {
+		return "", err
+	}
+	uploadID := created.UploadId
+
+	var parts []types.CompletedPart
+	for offset, number := int64(0), int32(1); offset < size; offset, number = offset+partSize, number+1 {
+		length := min(partSize, size-offset)
+		result, err := c.Client.UploadPart(context.TODO(), &s3.UploadPartInput{
+			Bucket:        aws.String(bucket),
+			Key:           aws.String(key),
+			UploadId:      uploadID,
+			PartNumber:    aws.Int32(number),
+			Body:          io.NewSectionReader(f, offset, length),
+			ContentLength: aws.Int64(length),
+		})
+		if err != nil {
+			c.abortUpload(bucket, key, uploadID)
+			return "", fmt.Errorf("part %d: %w", number, err)
+		}
+		parts = append(parts, types.CompletedPart{ETag: result.ETag, PartNumber: aws.Int32(number)})
+	}
+
+	completed, err := c.Client.CompleteMultipartUpload(context.TODO(), &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(key),
+		UploadId:        uploadID,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+	if err != nil {
+		c.abortUpload(bucket, key, uploadID)
+		return "", err
+	}
+	return aws.ToString(completed.ETag), nil
+}
+
+// abortUpload drops the parts of a failed upload, an abort failure only leaves parts a lifecycle rule can clean up so it is ignored
+func (c *S3) abortUpload(bucket, key string, uploadID *string) {
+	_, _ = c.Client.AbortMultipartUpload(context.TODO(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(key),
+		UploadId: uploadID,
+	})
+}
+
+// DownloadArtifact downloads bucket/key to path and verifies it against the checksum stored on upload
+// The file is written next to path and renamed, so path never holds a partial or corrupt artifact
+func (c *S3) DownloadArtifact(bucket, key, path string) (Artifact, error) {
+	artifact := Artifact{Bucket: bucket, Key: key}
+
+	result, err := c.Client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return artifact, fmt.Errorf("failed to download s3://%s/%s: %w", bucket, key, err)
+	}
+	defer result.Body.Close()
+	artifact.ETag = aws.ToString(result.ETag)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return artifact, fmt.Errorf("could not create %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), result.Body)
+	if err != nil {
+		tmp.Close()
+		return artifact, fmt.Errorf("failed to download s3://%s/%s: %w", bucket, key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return artifact, fmt.Errorf("could not write %s: %w", path, err)
+	}
+	artifact.Size = size
+	artifact.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	if expected := result.Metadata[checksumMetadata]; expected != "" && !strings.EqualFold(expected, artifact.Checksum) {
+		return artifact, fmt.Errorf("checksum mismatch for s3://%s/%s: expected %s, got %s", bucket, key, expected, artifact.Checksum)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return artifact, fmt.Errorf("could not write %s: %w", path, err)
+	}
+
+	return artifact, nil
+}
+
+// HeadArtifact returns the size and stored checksum of bucket/key without downloading it
+func (c *S3) HeadArtifact(bucket, key string) (Artifact, error) {
+	result, err := c.Client.HeadObject(context.TODO(), &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return Artifact{}, fmt.Errorf("failed to head s3://%s/%s: %w", bucket, key, err)
+	}
+
+	return Artifact{
+		Bucket:   bucket,
+		Key:      key,
+		Size:     aws.ToInt64(result.ContentLength),
+		Checksum: result.Metadata[checksumMetadata],
+		ETag:     aws.ToString(result.ETag),
+	}, nil
+}
+
+// PresignDownload returns a URL that downloads bucket/key without credentials until it expires
+func (c *S3) PresignDownload(bucket, key string, expires time.Duration) (string, error) {
+	request, err := c.Presigner.PresignGetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("failed to presign s3://%s/%s: %w", bucket, key, err)
+	}
+	return request.URL, nil
+}
+
+// DownloadToHost makes a host fetch bucket/key itself through a presigned URL instead of copying it over SCP from the deploy machine
+// The host needs curl and sha256sum, the download is verified against the stored checksum before it is moved to remotePath
+// The URL grants access to the object until it expires and is part of the command, so keep expires short
+// Executor errors leave the command out, but over SSM the command including the URL is kept in the Run Command history
+func (c *S3) DownloadToHost(executor utils.Executor, bucket, key, remotePath string, expires time.Duration) (Artifact, error) {
+	artifact, err := c.HeadArtifact(bucket, key)
+	if err != nil {
+		return artifact, err
+	}
+	url, err := c.PresignDownload(bucket, key, expires)
+	if err != nil {
+		return artifact, err
+	}
+
+	tmp := remotePath + ".download"
+	cmd := fmt.Sprintf("curl -fsSL -o %s %s", shellQuote(tmp), shellQuote(url))
+	if artifact.Checksum != "" {
+		cmd += fmt.Sprintf(" && echo %s | sha256sum -c -", shellQuote(artifact.Checksum+"  "+tmp))
+	}
+	cmd += fmt.Sprintf(" && mv %s %s || { rm -f %s; exit 1; }", shellQuote(tmp), shellQuote(remotePath), shellQuote(tmp))
+
+	result, err := executor.Exec(cmd)
+	if err != nil {
+		return artifact, fmt.Errorf("host could not download s3://%s/%s: %w: %s", bucket, key, err, strings.TrimSpace(result.Stderr))
+	}
+	return artifact, nil
+}
+
+func (c *S3) partSize() int64 {
+	if c.PartSize <= 0 {
+		return defaultPartSize
+	}
+	return max(c.PartSize, minPartSize)
+}
+
+// shellQuote quotes s as a single sh word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
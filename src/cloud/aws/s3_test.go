@@ -0,0 +1,285 @@
+package aws_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	s3Client "github.com/onurcevik/deploy-utilities/src/cloud/aws"
+	"github.com/onurcevik/deploy-utilities/src/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockS3API is a mock type for the S3API interface
+type MockS3API struct {
+	mock.Mock
+}
+
+func (m *MockS3API) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*s3.PutObjectOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockS3API) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*s3.CreateMultipartUploadOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockS3API) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*s3.UploadPartOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockS3API) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*s3.CompleteMultipartUploadOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockS3API) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*s3.AbortMultipartUploadOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockS3API) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*s3.GetObjectOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockS3API) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*s3.HeadObjectOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+// MockS3Presigner is a mock type for the S3PresignAPI interface
+type MockS3Presigner struct {
+	mock.Mock
+}
+
+func (m *MockS3Presigner) PresignGetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*v4.PresignedHTTPRequest)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+// MockExecutor is a mock type for the utils.Executor interface
+type MockExecutor struct {
+	mock.Mock
+}
+
+func (m *MockExecutor) Exec(cmd string) (utils.ExecResult, error) {
+	args := m.Called(cmd)
+	return args.Get(0).(utils.ExecResult), args.Error(1)
+}
+
+func writeArtifact(t *testing.T, size int) (string, []byte, string) {
+	content := bytes.Repeat([]byte("artifact"), size/8+1)[:size]
+	path := filepath.Join(t.TempDir(), "app.tar.gz")
+	require.NoError(t, os.WriteFile(path, content, 0644))
+	sum := sha256.Sum256(content)
+	return path, content, hex.EncodeToString(sum[:])
+}
+
+func TestUploadArtifactSinglePut(t *testing.T) {
+	mockS3 := new(MockS3API)
+	client := &s3Client.S3{Client: mockS3}
+	path, content, checksum := writeArtifact(t, 1024)
+
+	mockS3.On("PutObject", mock.Anything, mock.MatchedBy(func(input *s3.PutObjectInput) bool {
+		body, _ := io.ReadAll(input.Body)
+		return aws.ToString(input.Key) == "builds/app.tar.gz" && input.Metadata["sha256"] == checksum && bytes.Equal(body, content)
+	}), mock.Anything).Return(&s3.PutObjectOutput{ETag: aws.String(`"etag"`)}, nil)
+
+	artifact, err := client.UploadArtifact(path, "artifacts", "builds/app.tar.gz")
+	require.NoError(t, err)
+	assert.Equal(t, s3Client.Artifact{Bucket: "artifacts", Key: "builds/app.tar.gz", Size: 1024, Checksum: checksum, ETag: `"etag"`}, artifact)
+	mockS3.AssertNotCalled(t, "CreateMultipartUpload", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUploadArtifactMultipart(t *testing.T) {
+	mockS3 := new(MockS3API)
+	// Part sizes below the S3 minimum are raised to 5MiB
+	client := &s3Client.S3{Client: mockS3, PartSize: 1}
+	path, content, checksum := writeArtifact(t, 11<<20)
+
+	mockS3.On("CreateMultipartUpload", mock.Anything, mock.MatchedBy(func(input *s3.CreateMultipartUploadInput) bool {
+		return input.Metadata["sha256"] == checksum
+	}), mock.Anything).Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil)
+
+	var uploaded bytes.Buffer
+	var sizes []int64
+	mockS3.On("UploadPart", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(1).(*s3.UploadPartInput)
+		n, _ := io.Copy(&uploaded, input.Body)
+		sizes = append(sizes, n)
+	}).Return(&s3.UploadPartOutput{ETag: aws.String("part")}, nil)
+	mockS3.On("CompleteMultipartUpload", mock.Anything, mock.MatchedBy(func(input *s3.CompleteMultipartUploadInput) bool {
+		parts := input.MultipartUpload.Parts
+		return aws.ToString(input.UploadId) == "upload-1" && len(parts) == 3 && aws.ToInt32(parts[2].PartNumber) == 3
+	}), mock.Anything).Return(&s3.CompleteMultipartUploadOutput{ETag: aws.String(`"etag-3"`)}, nil)
+
+	artifact, err := client.UploadArtifact(path, "artifacts", "builds/app.tar.gz")
+	require.NoError(t, err)
+	assert.Equal(t, `"etag-3"`, artifact.ETag)
+	assert.Equal(t, []int64{5 << 20, 5 << 20, 1 << 20}, sizes)
+	assert.Equal(t, content, uploaded.Bytes())
+	mockS3.AssertExpectations(t)
+}
+
+func TestUploadArtifactMultipartAbortsOnFailure(t *testing.T) {
+	mockS3 := new(MockS3API)
+	client := &s3Client.S3{Client: mockS3, PartSize: 5 << 20}
+	path, _, _ := writeArtifact(t, 6<<20)
+
+	mockS3.On("CreateMultipartUpload", mock.Anything, mock.Anything, mock.Anything).Return(&s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-1")}, nil)
+	mockS3.On("UploadPart", mock.Anything, mock.Anything, mock.Anything).Return(&s3.UploadPartOutput{ETag: aws.String("part")}, nil).Once()
+	mockS3.On("UploadPart", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("connection reset")).Once()
+	mockS3.On("AbortMultipartUpload", mock.Anything, mock.MatchedBy(func(input *s3.AbortMultipartUploadInput) bool {
+		return aws.ToString(input.UploadId) == "upload-1"
+	}), mock.Anything).Return(&s3.AbortMultipartUploadOutput{}, nil)
+
+	_, err := client.UploadArtifact(path, "artifacts", "builds/app.tar.gz")
+	assert.ErrorContains(t, err, "part 2: connection reset")
+	mockS3.AssertExpectations(t)
+	mockS3.AssertNotCalled(t, "CompleteMultipartUpload", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestDownloadArtifact(t *testing.T) {
+	_, content, checksum := writeArtifact(t, 2048)
+	dest := filepath.Join(t.TempDir(), "app.tar.gz")
+
+	mockS3 := new(MockS3API)
+	client := &s3Client.S3{Client: mockS3}
+	mockS3.On("GetObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+		Body:     io.NopCloser(bytes.NewReader(content)),
+		Metadata: map[string]string{"sha256": checksum},
+	}, nil)
+
+	artifact, err := client.DownloadArtifact("artifacts", "builds/app.tar.gz", dest)
+	require.NoError(t, err)
+	assert.Equal(t, checksum, artifact.Checksum)
+	assert.Equal(t, int64(2048), artifact.Size)
+
+	downloaded, err := os.ReadFile(dest)
+	require.NoError(t, err)
+	assert.Equal(t, content, downloaded)
+}
+
+func TestDownloadArtifactChecksumMismatch(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "app.tar.gz")
+
+	mockS3 := new(MockS3API)
+	client := &s3Client.S3{Client: mockS3}
+	mockS3.On("GetObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.GetObjectOutput{
+		Body:     io.NopCloser(bytes.NewReader([]byte("corrupt"))),
+		Metadata: map[string]string{"sha256": "0000"},
+	}, nil)
+
+	_, err := client.DownloadArtifact("artifacts", "builds/app.tar.gz", dest)
+	assert.ErrorContains(t, err, "checksum mismatch")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDownloadToHost(t *testing.T) {
+	mockS3 := new(MockS3API)
+	mockPresigner := new(MockS3Presigner)
+	mockExecutor := new(MockExecutor)
+	client := &s3Client.S3{Client: mockS3, Presigner: mockPresigner}
+
+	mockS3.On("HeadObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{
+		ContentLength: aws.Int64(2048),
+		Metadata:      map[string]string{"sha256": "abc123"},
+	}, nil)
+	mockPresigner.On("PresignGetObject", mock.Anything, &s3.GetObjectInput{
+		Bucket: aws.String("artifacts"),
+		Key:    aws.String("builds/app.tar.gz"),
+	}, mock.Anything).Return(&v4.PresignedHTTPRequest{URL: "https://artifacts.s3.amazonaws.com/builds/app.tar.gz?X-Amz-Signature=sig"}, nil)
+	mockExecutor.On("Exec", "curl -fsSL -o '/opt/app/app.tar.gz.download' 'https://artifacts.s3.amazonaws.com/builds/app.tar.gz?X-Amz-Signature=sig'"+
+		" && echo 'abc123  /opt/app/app.tar.gz.download' | sha256sum -c -"+
+		" && mv '/opt/app/app.tar.gz.download' '/opt/app/app.tar.gz' || { rm -f '/opt/app/app.tar.gz.download'; exit 1; }").
+		Return(utils.ExecResult{}, nil)
+
+	artifact, err := client.DownloadToHost(mockExecutor, "artifacts", "builds/app.tar.gz", "/opt/app/app.tar.gz", 5*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", artifact.Checksum)
+	mockExecutor.AssertExpectations(t)
+}
+
+func TestDownloadToHostFailure(t *testing.T) {
+	mockS3 := new(MockS3API)
+	mockPresigner := new(MockS3Presigner)
+	mockExecutor := new(MockExecutor)
+	client := &s3Client.S3{Client: mockS3, Presigner: mockPresigner}
+
+	mockS3.On("HeadObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{}, nil)
+	mockPresigner.On("PresignGetObject", mock.Anything, mock.Anything, mock.Anything).Return(&v4.PresignedHTTPRequest{URL: "https://example"}, nil)
+	mockExecutor.On("Exec", mock.Anything).Return(utils.ExecResult{ExitCode: 22, Stderr: "curl: (22) 403\n"}, utils.ErrNonZeroExit)
+
+	_, err := client.DownloadToHost(mockExecutor, "artifacts", "builds/app.tar.gz", "/opt/app/app.tar.gz", time.Minute)
+	assert.ErrorIs(t, err, utils.ErrNonZeroExit)
+	assert.ErrorContains(t, err, "curl: (22) 403")
+}
+
+func TestDownloadToHostErrorHidesPresignedURL(t *testing.T) {
+	mockS3 := new(MockS3API)
+	mockPresigner := new(MockS3Presigner)
+	mockSSM := new(MockSSMAPI)
+	client := &s3Client.S3{Client: mockS3, Presigner: mockPresigner}
+	executor := (&s3Client.SSM{Client: mockSSM, PollInterval: time.Millisecond}).Executor("i-1")
+
+	mockS3.On("HeadObject", mock.Anything, mock.Anything, mock.Anything).Return(&s3.HeadObjectOutput{}, nil)
+	mockPresigner.On("PresignGetObject", mock.Anything, mock.Anything, mock.Anything).Return(&v4.PresignedHTTPRequest{URL: "https://example?X-Amz-Signature=sig"}, nil)
+	sentCommand(mockSSM)
+	mockSSM.On("GetCommandInvocation", mock.Anything, mock.Anything, mock.Anything).Return(invocation(ssmtypes.CommandInvocationStatusFailed, 22, "", "curl: (22) 403"), nil)
+
+	_, err := client.DownloadToHost(executor, "artifacts", "builds/app.tar.gz", "/opt/app/app.tar.gz", time.Minute)
+	assert.ErrorIs(t, err, utils.ErrNonZeroExit)
+	assert.NotContains(t, err.Error(), "X-Amz-Signature")
+}
@@ -0,0 +1,135 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/onurcevik/deploy-utilities/common/config"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// SecretsManagerAPI interface added in order to make mock testing easier, same as EC2API
+type SecretsManagerAPI interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+// SecretResolver resolves config secret references from SSM Parameter Store and Secrets Manager, it implements config.SecretResolver
+// Values are cached so a parameter or secret referenced several times is fetched only once
+type SecretResolver struct {
+	SSM            SSMAPI
+	SecretsManager SecretsManagerAPI
+
+	mu    sync.Mutex
+	cache map[string]string
+}
+
+// NewSecretResolver initializes the ssm and secretsmanager clients used to resolve secrets
+func NewSecretResolver(ctx context.Context, logger slog.Logger, accessKey, secretAccessKey, session string) *SecretResolver {
+	cfg := loadConfig(ctx, logger, accessKey, secretAccessKey, session)
+	return &SecretResolver{
+		SSM:            ssm.NewFromConfig(cfg),
+		SecretsManager: secretsmanager.NewFromConfig(cfg),
+	}
+}
+
+// ResolveSecret returns the value of an ssm:/path/to/param or secretsmanager:name#key reference
+// SecureString parameters are decrypted, a Secrets Manager key selects a field of a JSON secret
+func (r *SecretResolver) ResolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, config.SSMSecretPrefix):
+		return r.parameter(strings.TrimPrefix(ref, config.SSMSecretPrefix))
+	case strings.HasPrefix(ref, config.SecretsManagerPrefix):
+		name, key, _ := strings.Cut(strings.TrimPrefix(ref, config.SecretsManagerPrefix), "#")
+		secret, err := r.secret(name)
+		if err != nil {
+			return "", err
+		}
+		if key == "" {
+			return secret, nil
+		}
+		return secretKey(name, secret, key)
+	}
+	return "", fmt.Errorf("unsupported secret reference %s", ref)
+}
+
+// parameter returns the decrypted value of an SSM parameter
+func (r *SecretResolver) parameter(name string) (string, error) {
+	if value, ok := r.cached(config.SSMSecretPrefix + name); ok {
+		return value, nil
+	}
+
+	result, err := r.SSM.GetParameter(context.TODO(), &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get parameter %s: %w", name, err)
+	}
+	if result.Parameter == nil {
+		return "", fmt.Errorf("parameter %s has no value", name)
+	}
+
+	value := aws.ToString(result.Parameter.Value)
+	r.store(config.SSMSecretPrefix+name, value)
+	return value, nil
+}
+
+// secret returns the secret string of a Secrets Manager secret, the whole secret is cached so every key is read from one call
+func (r *SecretResolver) secret(name string) (string, error) {
+	if value, ok := r.cached(config.SecretsManagerPrefix + name); ok {
+		return value, nil
+	}
+
+	result, err := r.SecretsManager.GetSecretValue(context.TODO(), &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", name, err)
+	}
+	if result.SecretString == nil {
+		return "", fmt.Errorf("secret %s is binary, only string secrets are supported", name)
+	}
+
+	value := aws.ToString(result.SecretString)
+	r.store(config.SecretsManagerPrefix+name, value)
+	return value, nil
+}
+
+// secretKey returns a field of a JSON secret, non string fields are returned in their JSON form
+func secretKey(name, secret, key string) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object, can't read key %s: %w", name, key, err)
+	}
+	raw, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", name, key)
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw), nil
+	}
+	return value, nil
+}
+
+func (r *SecretResolver) cached(ref string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, ok := r.cache[ref]
+	return value, ok
+}
+
+func (r *SecretResolver) store(ref, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cache == nil {
+		r.cache = map[string]string{}
+	}
+	r.cache[ref] = value
+}
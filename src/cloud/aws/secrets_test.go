@@ -0,0 +1,133 @@
+package aws_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/onurcevik/deploy-utilities/common/config"
+	secretsClient "github.com/onurcevik/deploy-utilities/src/cloud/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockSecretsManagerAPI is a mock type for the SecretsManagerAPI interface
+type MockSecretsManagerAPI struct {
+	mock.Mock
+}
+
+func (m *MockSecretsManagerAPI) GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*secretsmanager.GetSecretValueOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func TestResolveSecretParameter(t *testing.T) {
+	mockSSM := new(MockSSMAPI)
+	resolver := &secretsClient.SecretResolver{SSM: mockSSM}
+
+	mockSSM.On("GetParameter", mock.Anything, &ssm.GetParameterInput{
+		Name:           aws.String("/deploy/ghcr/password"),
+		WithDecryption: aws.Bool(true),
+	}, mock.Anything).Return(&ssm.GetParameterOutput{
+		Parameter: &types.Parameter{Value: aws.String("s3cret")},
+	}, nil).Once()
+
+	for i := 0; i < 2; i++ {
+		value, err := resolver.ResolveSecret("ssm:/deploy/ghcr/password")
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", value)
+	}
+	mockSSM.AssertExpectations(t)
+}
+
+func TestResolveSecretSecretsManagerKeys(t *testing.T) {
+	mockSecrets := new(MockSecretsManagerAPI)
+	resolver := &secretsClient.SecretResolver{SecretsManager: mockSecrets}
+
+	mockSecrets.On("GetSecretValue", mock.Anything, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String("deploy/db"),
+	}, mock.Anything).Return(&secretsmanager.GetSecretValueOutput{
+		SecretString: aws.String(`{"url": "postgres://db:5432/app", "port": 5432}`),
+	}, nil).Once()
+
+	url, err := resolver.ResolveSecret("secretsmanager:deploy/db#url")
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://db:5432/app", url)
+
+	port, err := resolver.ResolveSecret("secretsmanager:deploy/db#port")
+	require.NoError(t, err)
+	assert.Equal(t, "5432", port)
+
+	whole, err := resolver.ResolveSecret("secretsmanager:deploy/db")
+	require.NoError(t, err)
+	assert.Contains(t, whole, `"url"`)
+
+	_, err = resolver.ResolveSecret("secretsmanager:deploy/db#user")
+	assert.ErrorContains(t, err, "secret deploy/db has no key user")
+	mockSecrets.AssertExpectations(t)
+}
+
+func TestResolveSecretsInConfig(t *testing.T) {
+	mockSSM := new(MockSSMAPI)
+	resolver := &secretsClient.SecretResolver{SSM: mockSSM}
+
+	mockSSM.On("GetParameter", mock.Anything, mock.MatchedBy(func(input *ssm.GetParameterInput) bool {
+		return aws.ToString(input.Name) == "/deploy/ghcr/password"
+	}), mock.Anything).Return(&ssm.GetParameterOutput{Parameter: &types.Parameter{Value: aws.String("s3cret")}}, nil)
+	mockSSM.On("GetParameter", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("ParameterNotFound"))
+
+	conf := config.Config{Docker: config.Docker{Registries: []config.Registry{
+		{Server: "ghcr.io", Username: "deployer", Password: "ssm:/deploy/ghcr/password"},
+	}}}
+	require.NoError(t, conf.ResolveSecrets(resolver))
+	assert.Equal(t, "deployer", conf.Docker.Registries[0].Username)
+	assert.Equal(t, "s3cret", conf.Docker.Registries[0].Password)
+
+	conf.Docker.Registries = append(conf.Docker.Registries, config.Registry{Server: "ecr", Password: "ssm:/missing"})
+	err := conf.ResolveSecrets(resolver)
+	assert.ErrorContains(t, err, "could not resolve docker.registries[1].password")
+}
+
+const secretEnvFile = `aws:
+  aws_access_key: "key"
+  aws_secret_access_key: "secret"
+docker:
+  registries:
+    - server: "ghcr.io"
+      username: "deployer"
+      password: "ssm:/deploy/ghcr/password"
+`
+
+func TestNewConfigResolvesSecrets(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "env.yaml"), []byte(secretEnvFile), 0644))
+
+	mockSSM := new(MockSSMAPI)
+	mockSSM.On("GetParameter", mock.Anything, mock.Anything, mock.Anything).Return(&ssm.GetParameterOutput{
+		Parameter: &types.Parameter{Value: aws.String("s3cret")},
+	}, nil)
+
+	var resolverAWS config.AWS
+	conf, err := config.NewConfig(dir, "env.yaml", config.WithSecretResolver(func(a config.AWS) config.SecretResolver {
+		resolverAWS = a
+		return &secretsClient.SecretResolver{SSM: mockSSM}
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", conf.Docker.Registries[0].Password)
+	assert.Equal(t, "key", resolverAWS.AWSAccessKey)
+
+	// Without a resolver the reference must never be used as the password
+	_, err = config.NewConfig(dir, "env.yaml")
+	assert.ErrorContains(t, err, "unresolved secret references in docker.registries[0].password")
+}
@@ -0,0 +1,229 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/onurcevik/deploy-utilities/src/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// defaultSSHUserTag is the instance tag that overrides the detected ssh user
+	defaultSSHUserTag = "ssh-user"
+	// defaultSSHUser is used when the user can't be derived from tags or the AMI, it is the Amazon Linux and RHEL user
+	defaultSSHUser = "ec2-user"
+)
+
+// AddressPolicy decides which IP address of an instance SSH targets use
+type AddressPolicy int
+
+const (
+	// PreferPrivate uses the private IP, it suits runners inside the VPC or behind a jump host
+	PreferPrivate AddressPolicy = iota
+	// PreferPublic uses the public IP and falls back to the private one
+	PreferPublic
+	// PublicOnly fails for instances without a public IP
+	PublicOnly
+)
+
+// amiUsers maps AMI name prefixes to the default user of the distribution, the first matching prefix wins
+var amiUsers = []struct {
+	prefix string
+	user   string
+}{
+	{"ubuntu", "ubuntu"},
+	{"debian", "admin"},
+	{"centos", "centos"},
+	{"fedora", "fedora"},
+	{"bitnami", "bitnami"},
+	{"amzn", "ec2-user"},
+	{"al2023", "ec2-user"},
+	{"rhel", "ec2-user"},
+	{"suse", "ec2-user"},
+}
+
+// SSHTargetOptions configures how instances are turned into SSH targets
+type SSHTargetOptions struct {
+	AddressPolicy AddressPolicy
+	// KeyDir holds private keys named after the EC2 key pair, <KeyName>.pem or <KeyName>
+	// When it is empty or the instance has no key pair the target has no IdentityFile and only SSHTarget.Executor uses the ssh agent and config for it
+	KeyDir string
+	// UserTag is the instance tag that sets the user, defaults to ssh-user
+	UserTag string
+	// DefaultUser is used when neither the tag nor the AMI gives a user, defaults to ec2-user
+	DefaultUser string
+	// DetectUser looks up the AMI names of instances without a user tag to pick the distribution default user
+	DetectUser bool
+	// JumpHost is set on every target
+	JumpHost string
+}
+
+// SSHTarget is an instance with the SSHContext to reach it, run commands through Executor and copy files with utils.SCP
+// Don't pass targets without an IdentityFile to utils.RemoteExec, it falls back to a fixed key instead of the ssh agent and config
+type SSHTarget struct {
+	InstanceID string
+	utils.SSHContext
+}
+
+// Executor returns an utils.Executor running commands on the target over ssh
+func (t SSHTarget) Executor() *utils.SSHExecutor {
+	return utils.NewSSHExecutor(t.SSHContext)
+}
+
+// SSHTargets converts instances, such as the result of GetInstancesByTag, into SSH targets
+// Windows instances and instances without an address matching the policy are reported as errors, the other targets are still returned
+func (c *EC2) SSHTargets(instances []types.Instance, opts SSHTargetOptions) ([]SSHTarget, error) {
+	userTag := opts.UserTag
+	if userTag == "" {
+		userTag = defaultSSHUserTag
+	}
+
+	var amiNames map[string]string
+	if opts.DetectUser {
+		var err error
+		amiNames, err = c.imageNames(instances, userTag)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		targets []SSHTarget
+		errs    []error
+	)
+	for _, instance := range instances {
+		id := aws.ToString(instance.InstanceId)
+		if instance.Platform == types.PlatformValuesWindows {
+			errs = append(errs, fmt.Errorf("instance %s runs windows and has no ssh server", id))
+			continue
+		}
+
+		host, err := instanceAddress(instance, opts.AddressPolicy)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		identityFile, err := keyFile(opts.KeyDir, aws.ToString(instance.KeyName))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("instance %s: %w", id, err))
+			continue
+		}
+
+		targets = append(targets, SSHTarget{
+			InstanceID: id,
+			SSHContext: utils.SSHContext{
+				RemoteUser:   instanceUser(instance, userTag, amiNames, opts.DefaultUser),
+				RemoteHost:   host,
+				IdentityFile: identityFile,
+				JumpHost:     opts.JumpHost,
+			},
+		})
+	}
+
+	return targets, errors.Join(errs...)
+}
+
+// imageNames returns the names of the AMIs of instances that have no user tag, keyed by image ID
+func (c *EC2) imageNames(instances []types.Instance, userTag string) (map[string]string, error) {
+	ids := map[string]bool{}
+	for _, instance := range instances {
+		if _, ok := instanceTag(instance, userTag); !ok && instance.ImageId != nil {
+			ids[*instance.ImageId] = true
+		}
+	}
+	names := map[string]string{}
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	imageIDs := make([]string, 0, len(ids))
+	for id := range ids {
+		imageIDs = append(imageIDs, id)
+	}
+	sort.Strings(imageIDs)
+
+	result, err := c.Client.DescribeImages(context.TODO(), &ec2.DescribeImagesInput{ImageIds: imageIDs})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe images: %w", err)
+	}
+	for _, image := range result.Images {
+		names[aws.ToString(image.ImageId)] = aws.ToString(image.Name)
+	}
+
+	return names, nil
+}
+
+// instanceUser picks the ssh user from the user tag, then the AMI name, then the default user
+func instanceUser(instance types.Instance, userTag string, amiNames map[string]string, defaultUser string) string {
+	if user, ok := instanceTag(instance, userTag); ok && user != "" {
+		return user
+	}
+
+	name := strings.ToLower(amiNames[aws.ToString(instance.ImageId)])
+	for _, ami := range amiUsers {
+		if strings.HasPrefix(name, ami.prefix) || strings.Contains(name, "/"+ami.prefix) {
+			return ami.user
+		}
+	}
+
+	if defaultUser != "" {
+		return defaultUser
+	}
+	return defaultSSHUser
+}
+
+func instanceAddress(instance types.Instance, policy AddressPolicy) (string, error) {
+	public, private := aws.ToString(instance.PublicIpAddress), aws.ToString(instance.PrivateIpAddress)
+
+	switch policy {
+	case PublicOnly:
+		if public != "" {
+			return public, nil
+		}
+	case PreferPublic:
+		if public != "" {
+			return public, nil
+		}
+		if private != "" {
+			return private, nil
+		}
+	default:
+		if private != "" {
+			return private, nil
+		}
+	}
+
+	return "", fmt.Errorf("instance %s has no address matching the policy", aws.ToString(instance.InstanceId))
+}
+
+// keyFile maps an EC2 key pair name to a private key in keyDir
+func keyFile(keyDir, keyName string) (string, error) {
+	if keyDir == "" || keyName == "" {
+		return "", nil
+	}
+
+	for _, name := range []string{keyName + ".pem", keyName} {
+		path := filepath.Join(keyDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no private key for key pair %s in %s", keyName, keyDir)
+}
+
+func instanceTag(instance types.Instance, key string) (string, bool) {
+	for _, tag := range instance.Tags {
+		if aws.ToString(tag.Key) == key {
+			return aws.ToString(tag.Value), true
+		}
+	}
+	return "", false
+}
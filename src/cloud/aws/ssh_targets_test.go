@@ -0,0 +1,80 @@
+package aws_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ec2Client "github.com/onurcevik/deploy-utilities/src/cloud/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHTargets(t *testing.T) {
+	mockEC2 := new(MockEC2API)
+	client := &ec2Client.EC2{Client: mockEC2}
+	keyDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(keyDir, "deploy.pem"), []byte("key"), 0600))
+
+	mockEC2.On("DescribeImages", mock.Anything, &ec2.DescribeImagesInput{ImageIds: []string{"ami-ubuntu"}}, mock.Anything).
+		Return(&ec2.DescribeImagesOutput{Images: []types.Image{
+			{ImageId: aws.String("ami-ubuntu"), Name: aws.String("ubuntu/images/hvm-ssd/ubuntu-jammy-22.04-amd64-server-20240701")},
+		}}, nil)
+
+	targets, err := client.SSHTargets([]types.Instance{
+		{
+			InstanceId:       aws.String("i-1"),
+			ImageId:          aws.String("ami-ubuntu"),
+			KeyName:          aws.String("deploy"),
+			PrivateIpAddress: aws.String("10.0.1.10"),
+			PublicIpAddress:  aws.String("54.1.2.3"),
+		},
+		{
+			InstanceId:       aws.String("i-2"),
+			ImageId:          aws.String("ami-custom"),
+			PrivateIpAddress: aws.String("10.0.1.11"),
+			Tags:             []types.Tag{{Key: aws.String("ssh-user"), Value: aws.String("deployer")}},
+		},
+	}, ec2Client.SSHTargetOptions{
+		AddressPolicy: ec2Client.PreferPublic,
+		KeyDir:        keyDir,
+		DetectUser:    true,
+		JumpHost:      "bastion.example.com",
+	})
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+
+	assert.Equal(t, "i-1", targets[0].InstanceID)
+	assert.Equal(t, "ubuntu@54.1.2.3", targets[0].Destination())
+	assert.Equal(t, filepath.Join(keyDir, "deploy.pem"), targets[0].IdentityFile)
+	assert.Equal(t, "bastion.example.com", targets[0].JumpHost)
+
+	// The tag wins over the AMI and the private IP is used when there is no public one
+	assert.Equal(t, "deployer@10.0.1.11", targets[1].Destination())
+	assert.Empty(t, targets[1].IdentityFile)
+	// Keyless targets pass no identity file to ssh so the agent and config pick the key
+	assert.Equal(t, []string{"-J", "bastion.example.com"}, targets[1].Executor().SSHOptions())
+
+	mockEC2.AssertExpectations(t)
+}
+
+func TestSSHTargetsReportsUnreachableInstances(t *testing.T) {
+	client := &ec2Client.EC2{Client: new(MockEC2API)}
+
+	targets, err := client.SSHTargets([]types.Instance{
+		{InstanceId: aws.String("i-1"), PublicIpAddress: aws.String("54.1.2.3")},
+		{InstanceId: aws.String("i-2"), PrivateIpAddress: aws.String("10.0.1.11")},
+		{InstanceId: aws.String("i-3"), PublicIpAddress: aws.String("54.1.2.4"), Platform: types.PlatformValuesWindows},
+		{InstanceId: aws.String("i-4"), PublicIpAddress: aws.String("54.1.2.5"), KeyName: aws.String("missing")},
+	}, ec2Client.SSHTargetOptions{AddressPolicy: ec2Client.PublicOnly, KeyDir: t.TempDir(), DefaultUser: "admin"})
+
+	require.Len(t, targets, 1)
+	assert.Equal(t, "admin@54.1.2.3", targets[0].Destination())
+	assert.ErrorContains(t, err, "instance i-2 has no address matching the policy")
+	assert.ErrorContains(t, err, "instance i-3 runs windows")
+	assert.ErrorContains(t, err, "no private key for key pair missing")
+}
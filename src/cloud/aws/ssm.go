@@ -0,0 +1,148 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/onurcevik/deploy-utilities/src/utils"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultCommandPollInterval is how often RunCommand checks the invocation when PollInterval is zero
+	defaultCommandPollInterval = 2 * time.Second
+	// defaultCommandTimeout is how long RunCommand waits for a command when Timeout is zero, same as the AWS-RunShellScript default
+	defaultCommandTimeout = time.Hour
+	// runShellScript is the SSM document that runs shell commands on linux instances
+	runShellScript = "AWS-RunShellScript"
+)
+
+// SSMAPI interface added in order to make mock testing easier, same as EC2API
+type SSMAPI interface {
+	SendCommand(ctx context.Context, params *ssm.SendCommandInput, optFns ...func(*ssm.Options)) (*ssm.SendCommandOutput, error)
+	GetCommandInvocation(ctx context.Context, params *ssm.GetCommandInvocationInput, optFns ...func(*ssm.Options)) (*ssm.GetCommandInvocationOutput, error)
+	CancelCommand(ctx context.Context, params *ssm.CancelCommandInput, optFns ...func(*ssm.Options)) (*ssm.CancelCommandOutput, error)
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// SSM struct runs commands on instances through SSM Run Command, PollInterval and Timeout default to 2 seconds and 1 hour
+type SSM struct {
+	Client       SSMAPI
+	PollInterval time.Duration
+	Timeout      time.Duration
+}
+
+// SSMExecutor runs commands on a single instance through SSM, it implements utils.Executor so it can replace an SSH transport
+type SSMExecutor struct {
+	SSM        *SSM
+	InstanceID string
+}
+
+// NewSSM initializes new ssm client to use
+func NewSSM(ctx context.Context, logger slog.Logger, accessKey, secretAccessKey, session string) *SSM {
+	cfg := loadConfig(ctx, logger, accessKey, secretAccessKey, session)
+	return &SSM{Client: ssm.NewFromConfig(cfg)}
+}
+
+// Executor returns an utils.Executor running commands on the instance
+func (c *SSM) Executor(instanceID string) *SSMExecutor {
+	return &SSMExecutor{SSM: c, InstanceID: instanceID}
+}
+
+// Exec runs cmd on the instance, see SSM.RunCommand
+func (e *SSMExecutor) Exec(cmd string) (utils.ExecResult, error) {
+	return e.SSM.RunCommand(e.InstanceID, cmd)
+}
+
+// RunCommand runs cmd with AWS-RunShellScript on the instance and waits for it to finish
+// The result has the same semantics as utils.SSHExecutor, SSM truncates stdout and stderr to 24000 characters each
+// A command still running when Timeout passes is cancelled so it doesn't keep running on the instance unnoticed
+func (c *SSM) RunCommand(instanceID, cmd string) (utils.ExecResult, error) {
+	result := utils.ExecResult{ExitCode: -1}
+
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultCommandTimeout
+	}
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = defaultCommandPollInterval
+	}
+
+	// SSM takes whole seconds and rejects 0, so sub-second timeouts are rounded up
+	executionTimeout := max(int(math.Ceil(timeout.Seconds())), 1)
+	sent, err := c.Client.SendCommand(context.TODO(), &ssm.SendCommandInput{
+		DocumentName: aws.String(runShellScript),
+		InstanceIds:  []string{instanceID},
+		Parameters: map[string][]string{
+			"commands":         {cmd},
+			"executionTimeout": {strconv.Itoa(executionTimeout)},
+		},
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to send command to %s: %w", instanceID, err)
+	}
+	commandID := aws.ToString(sent.Command.CommandId)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		invocation, err := c.Client.GetCommandInvocation(context.TODO(), &ssm.GetCommandInvocationInput{
+			CommandId:  aws.String(commandID),
+			InstanceId: aws.String(instanceID),
+		})
+		// The invocation only becomes visible shortly after the command was sent
+		var notYet *types.InvocationDoesNotExist
+		if err != nil && !errors.As(err, &notYet) {
+			return result, fmt.Errorf("failed to get command %s on %s: %w", commandID, instanceID, err)
+		}
+
+		if err == nil {
+			switch invocation.Status {
+			case types.CommandInvocationStatusPending, types.CommandInvocationStatusInProgress,
+				types.CommandInvocationStatusDelayed, types.CommandInvocationStatusCancelling:
+			default:
+				return invocationResult(instanceID, invocation)
+			}
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			_, err := c.Client.CancelCommand(context.TODO(), &ssm.CancelCommandInput{
+				CommandId:   aws.String(commandID),
+				InstanceIds: []string{instanceID},
+			})
+			if err != nil {
+				return result, fmt.Errorf("command %s on %s did not finish within %s and could not be cancelled, it may still be running: %w", commandID, instanceID, timeout, err)
+			}
+			return result, fmt.Errorf("command %s on %s did not finish within %s and was cancelled", commandID, instanceID, timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// invocationResult converts a finished invocation, commands that never ran on the instance keep the exit code -1
+func invocationResult(instanceID string, invocation *ssm.GetCommandInvocationOutput) (utils.ExecResult, error) {
+	result := utils.ExecResult{
+		Stdout:   aws.ToString(invocation.StandardOutputContent),
+		Stderr:   aws.ToString(invocation.StandardErrorContent),
+		ExitCode: int(invocation.ResponseCode),
+	}
+
+	switch invocation.Status {
+	case types.CommandInvocationStatusSuccess:
+		return result, nil
+	case types.CommandInvocationStatusFailed:
+		if result.ExitCode > 0 {
+			return result, fmt.Errorf("command on %s: %w (exit code %d)", instanceID, utils.ErrNonZeroExit, result.ExitCode)
+		}
+	}
+
+	// Cancelled, timed out or undeliverable commands, the exit code SSM reports for them doesn't come from the command
+	result.ExitCode = -1
+	return result, fmt.Errorf("command on %s ended with status %s: %s", instanceID, invocation.Status, aws.ToString(invocation.StatusDetails))
+}
@@ -0,0 +1,167 @@
+package aws_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	ssmClient "github.com/onurcevik/deploy-utilities/src/cloud/aws"
+	"github.com/onurcevik/deploy-utilities/src/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockSSMAPI is a mock type for the SSMAPI interface
+type MockSSMAPI struct {
+	mock.Mock
+}
+
+func (m *MockSSMAPI) SendCommand(ctx context.Context, params *ssm.SendCommandInput, optFns ...func(*ssm.Options)) (*ssm.SendCommandOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*ssm.SendCommandOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockSSMAPI) GetCommandInvocation(ctx context.Context, params *ssm.GetCommandInvocationInput, optFns ...func(*ssm.Options)) (*ssm.GetCommandInvocationOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*ssm.GetCommandInvocationOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockSSMAPI) CancelCommand(ctx context.Context, params *ssm.CancelCommandInput, optFns ...func(*ssm.Options)) (*ssm.CancelCommandOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*ssm.CancelCommandOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+func (m *MockSSMAPI) GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	args := m.Called(ctx, params, optFns)
+	output, ok := args.Get(0).(*ssm.GetParameterOutput)
+	if !ok {
+		return nil, args.Error(1)
+	}
+	return output, args.Error(1)
+}
+
+var (
+	_ utils.Executor = (*ssmClient.SSMExecutor)(nil)
+	_ utils.Executor = (*utils.SSHExecutor)(nil)
+)
+
+func sentCommand(mockSSM *MockSSMAPI) {
+	mockSSM.On("SendCommand", mock.Anything, mock.Anything, mock.Anything).Return(&ssm.SendCommandOutput{
+		Command: &types.Command{CommandId: aws.String("cmd-1")},
+	}, nil)
+}
+
+func invocation(status types.CommandInvocationStatus, code int32, stdout, stderr string) *ssm.GetCommandInvocationOutput {
+	return &ssm.GetCommandInvocationOutput{
+		Status:                status,
+		ResponseCode:          code,
+		StandardOutputContent: aws.String(stdout),
+		StandardErrorContent:  aws.String(stderr),
+		StatusDetails:         aws.String(string(status)),
+	}
+}
+
+func TestSSMExecutorExec(t *testing.T) {
+	mockSSM := new(MockSSMAPI)
+	executor := (&ssmClient.SSM{Client: mockSSM, PollInterval: time.Millisecond, Timeout: 90 * time.Second}).Executor("i-1")
+
+	mockSSM.On("SendCommand", mock.Anything, &ssm.SendCommandInput{
+		DocumentName: aws.String("AWS-RunShellScript"),
+		InstanceIds:  []string{"i-1"},
+		Parameters: map[string][]string{
+			"commands":         {"docker ps"},
+			"executionTimeout": {"90"},
+		},
+	}, mock.Anything).Return(&ssm.SendCommandOutput{Command: &types.Command{CommandId: aws.String("cmd-1")}}, nil)
+	invocationInput := &ssm.GetCommandInvocationInput{CommandId: aws.String("cmd-1"), InstanceId: aws.String("i-1")}
+	mockSSM.On("GetCommandInvocation", mock.Anything, invocationInput, mock.Anything).Return(nil, &types.InvocationDoesNotExist{}).Once()
+	mockSSM.On("GetCommandInvocation", mock.Anything, invocationInput, mock.Anything).Return(invocation(types.CommandInvocationStatusInProgress, -1, "", ""), nil).Once()
+	mockSSM.On("GetCommandInvocation", mock.Anything, invocationInput, mock.Anything).Return(invocation(types.CommandInvocationStatusSuccess, 0, "CONTAINER ID", ""), nil).Once()
+
+	result, err := executor.Exec("docker ps")
+	require.NoError(t, err)
+	assert.Equal(t, utils.ExecResult{Stdout: "CONTAINER ID", ExitCode: 0}, result)
+	mockSSM.AssertExpectations(t)
+}
+
+func TestSSMRunCommandNonZeroExit(t *testing.T) {
+	mockSSM := new(MockSSMAPI)
+	client := &ssmClient.SSM{Client: mockSSM, PollInterval: time.Millisecond}
+
+	sentCommand(mockSSM)
+	mockSSM.On("GetCommandInvocation", mock.Anything, mock.Anything, mock.Anything).Return(invocation(types.CommandInvocationStatusFailed, 2, "", "no such file"), nil)
+
+	result, err := client.RunCommand("i-1", "cat /missing")
+	assert.ErrorIs(t, err, utils.ErrNonZeroExit)
+	assert.Equal(t, 2, result.ExitCode)
+	assert.Equal(t, "no such file", result.Stderr)
+}
+
+func TestSSMRunCommandNotDelivered(t *testing.T) {
+	mockSSM := new(MockSSMAPI)
+	client := &ssmClient.SSM{Client: mockSSM, PollInterval: time.Millisecond}
+
+	sentCommand(mockSSM)
+	mockSSM.On("GetCommandInvocation", mock.Anything, mock.Anything, mock.Anything).Return(invocation(types.CommandInvocationStatusTimedOut, -1, "", ""), nil)
+
+	result, err := client.RunCommand("i-1", "uptime")
+	assert.ErrorContains(t, err, "ended with status TimedOut")
+	assert.NotErrorIs(t, err, utils.ErrNonZeroExit)
+	assert.Equal(t, -1, result.ExitCode)
+}
+
+func TestSSMRunCommandTimeout(t *testing.T) {
+	mockSSM := new(MockSSMAPI)
+	client := &ssmClient.SSM{Client: mockSSM, PollInterval: time.Millisecond, Timeout: 10 * time.Millisecond}
+
+	mockSSM.On("SendCommand", mock.Anything, mock.MatchedBy(func(input *ssm.SendCommandInput) bool {
+		return input.Parameters["executionTimeout"][0] == "1"
+	}), mock.Anything).Return(&ssm.SendCommandOutput{Command: &types.Command{CommandId: aws.String("cmd-1")}}, nil)
+	mockSSM.On("GetCommandInvocation", mock.Anything, mock.Anything, mock.Anything).Return(invocation(types.CommandInvocationStatusInProgress, -1, "", ""), nil)
+	mockSSM.On("CancelCommand", mock.Anything, &ssm.CancelCommandInput{CommandId: aws.String("cmd-1"), InstanceIds: []string{"i-1"}}, mock.Anything).Return(&ssm.CancelCommandOutput{}, nil).Once()
+
+	_, err := client.RunCommand("i-1", "sleep 60")
+	assert.ErrorContains(t, err, "command cmd-1 on i-1 did not finish within 10ms and was cancelled")
+	mockSSM.AssertExpectations(t)
+}
+
+func TestSSMRunCommandTimeoutCancelFails(t *testing.T) {
+	mockSSM := new(MockSSMAPI)
+	client := &ssmClient.SSM{Client: mockSSM, PollInterval: time.Millisecond, Timeout: 10 * time.Millisecond}
+
+	sentCommand(mockSSM)
+	mockSSM.On("GetCommandInvocation", mock.Anything, mock.Anything, mock.Anything).Return(invocation(types.CommandInvocationStatusInProgress, -1, "", ""), nil)
+	mockSSM.On("CancelCommand", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("AccessDenied"))
+
+	_, err := client.RunCommand("i-1", "sleep 60")
+	assert.ErrorContains(t, err, "could not be cancelled, it may still be running: AccessDenied")
+}
+
+func TestSSMRunCommandSendError(t *testing.T) {
+	mockSSM := new(MockSSMAPI)
+	client := &ssmClient.SSM{Client: mockSSM}
+
+	mockSSM.On("SendCommand", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New("InvalidInstanceId"))
+
+	result, err := client.RunCommand("i-1", "uptime")
+	assert.ErrorContains(t, err, "failed to send command to i-1")
+	assert.Equal(t, -1, result.ExitCode)
+	mockSSM.AssertNotCalled(t, "GetCommandInvocation", mock.Anything, mock.Anything, mock.Anything)
+}
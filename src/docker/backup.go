@@ -0,0 +1,223 @@
+package docker
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/onurcevik/deploy-utilities/src/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// volumeHelperImage is the image of the short lived containers that mount a volume for backup and restore
+	volumeHelperImage = "busybox:1.36"
+	// volumeHelperMount is where the helper container mounts the volume, archive entries are prefixed with its base name
+	volumeHelperMount = "/volume"
+)
+
+// VolumeBackup is a tar.gz archive of a volume and the sha256 checksum stored next to it in <Path>.sha256
+type VolumeBackup struct {
+	Volume   string
+	Path     string
+	Checksum string
+	Size     int64
+}
+
+// BackupVolume archives the contents of a named volume into dir/<volume>-<timestamp>.tar.gz and writes its checksum file
+// The volume is mounted read-only into a helper container that is never started, so the backup never changes the volume
+// Files are copied while containers may still write to them, stop the writers first or the archive may be inconsistent
+// The archive is written next to its final path and renamed once complete, so a failed backup leaves no partial archive behind
+func (d *Docker) BackupVolume(volumeName, dir string) (VolumeBackup, error) {
+	backup := VolumeBackup{Volume: volumeName}
+
+	// Creating the helper would silently create a missing volume, so check it exists first
+	if _, err := d.Client.VolumeInspect(d.Ctx, volumeName); err != nil {
+		return backup, fmt.Errorf("could not inspect volume %s: %w", volumeName, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return backup, fmt.Errorf("could not create backup directory %s: %w", dir, err)
+	}
+
+	id, err := d.createVolumeHelper(volumeName, true, nil)
+	if err != nil {
+		return backup, err
+	}
+	defer d.removeVolumeHelper(id)
+
+	content, _, err := d.Client.CopyFromContainer(d.Ctx, id, volumeHelperMount)
+	if err != nil {
+		return backup, fmt.Errorf("could not read volume %s: %w", volumeName, err)
+	}
+	defer content.Close()
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.tar.gz", volumeName, time.Now().UTC().Format("20060102T150405Z")))
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*")
+	if err != nil {
+		return backup, fmt.Errorf("could not create backup file %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(tmp, hash))
+	if _, err := io.Copy(gz, content); err != nil {
+		tmp.Close()
+		return backup, fmt.Errorf("could not write backup of volume %s: %w", volumeName, err)
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return backup, fmt.Errorf("could not write backup of volume %s: %w", volumeName, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return backup, fmt.Errorf("could not write backup file %s: %w", path, err)
+	}
+
+	info, err := os.Stat(tmp.Name())
+	if err != nil {
+		return backup, fmt.Errorf("could not stat backup file %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return backup, fmt.Errorf("could not write backup file %s: %w", path, err)
+	}
+	backup.Path = path
+	backup.Size = info.Size()
+	backup.Checksum = hex.EncodeToString(hash.Sum(nil))
+
+	// Same format as sha256sum so the archive can also be checked with `sha256sum -c`
+	sum := fmt.Sprintf("%s  %s\n", backup.Checksum, filepath.Base(backup.Path))
+	if err := os.WriteFile(backup.Path+".sha256", []byte(sum), 0644); err != nil {
+		os.Remove(backup.Path)
+		return VolumeBackup{Volume: volumeName}, fmt.Errorf("could not write checksum file: %w", err)
+	}
+
+	return backup, nil
+}
+
+// RestoreVolume replaces the contents of a named volume with a backup archive, the volume is created if it doesn't exist
+// The archive is verified against its checksum file first, containers using the volume should be stopped while it is restored
+func (d *Docker) RestoreVolume(volumeName, archivePath string) error {
+	if _, err := VerifyVolumeBackup(archivePath); err != nil {
+		return err
+	}
+
+	if _, err := d.EnsureVolume(volumeName, VolumeOptions{}); err != nil {
+		return err
+	}
+
+	// The helper empties the volume, the archive is copied in after it exited
+	id, err := d.createVolumeHelper(volumeName, false, []string{"find", volumeHelperMount, "-mindepth", "1", "-delete"})
+	if err != nil {
+		return err
+	}
+	defer d.removeVolumeHelper(id)
+
+	if err := d.Client.ContainerStart(d.Ctx, id, container.StartOptions{}); err != nil {
+		return fmt.Errorf("could not start helper container for volume %s: %w", volumeName, err)
+	}
+	statusCh, errCh := d.Client.ContainerWait(d.Ctx, id, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("could not wait for helper container of volume %s: %w", volumeName, err)
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fmt.Errorf("could not empty volume %s, helper exited with code %d", volumeName, status.StatusCode)
+		}
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("could not open backup file %s: %w", archivePath, err)
+	}
+	defer f.Close()
+
+	// The daemon decompresses gzip archives itself, entries start with the mount directory so they are extracted at /
+	if err := d.Client.CopyToContainer(d.Ctx, id, "/", f, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("could not restore volume %s: %w", volumeName, err)
+	}
+
+	return nil
+}
+
+// VerifyVolumeBackup compares the archive with the checksum in <archivePath>.sha256 and returns the checksum
+func VerifyVolumeBackup(archivePath string) (string, error) {
+	sum, err := os.ReadFile(archivePath + ".sha256")
+	if err != nil {
+		return "", fmt.Errorf("could not read checksum file of %s: %w", archivePath, err)
+	}
+	fields := strings.Fields(string(sum))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("checksum file of %s is empty", archivePath)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("could not open backup file %s: %w", archivePath, err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", fmt.Errorf("could not read backup file %s: %w", archivePath, err)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if !strings.EqualFold(checksum, fields[0]) {
+		return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", archivePath, fields[0], checksum)
+	}
+	return checksum, nil
+}
+
+// FetchVolumeBackup copies a backup archive and its checksum file from a remote host into localDir and verifies it
+func FetchVolumeBackup(sshCtx utils.SSHContext, remotePath, localDir string) (string, error) {
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		return "", fmt.Errorf("could not create backup directory %s: %w", localDir, err)
+	}
+
+	localPath := filepath.Join(localDir, filepath.Base(remotePath))
+	if err := utils.SCPFrom(sshCtx, remotePath, localPath, false); err != nil {
+		return "", err
+	}
+	if err := utils.SCPFrom(sshCtx, remotePath+".sha256", localPath+".sha256", false); err != nil {
+		return "", err
+	}
+
+	if _, err := VerifyVolumeBackup(localPath); err != nil {
+		return "", err
+	}
+	return localPath, nil
+}
+
+// createVolumeHelper creates a helper container with the volume mounted at volumeHelperMount
+func (d *Docker) createVolumeHelper(volumeName string, readOnly bool, cmd []string) (string, error) {
+	if err := d.ensureImage(volumeHelperImage); err != nil {
+		return "", err
+	}
+
+	created, err := d.Client.ContainerCreate(d.Ctx, &container.Config{
+		Image: volumeHelperImage,
+		Cmd:   cmd,
+	}, &container.HostConfig{
+		Mounts: []mount.Mount{{
+			Type:     mount.TypeVolume,
+			Source:   volumeName,
+			Target:   volumeHelperMount,
+			ReadOnly: readOnly,
+		}},
+	}, nil, nil, "")
+	if err != nil {
+		return "", fmt.Errorf("could not create helper container for volume %s: %w", volumeName, err)
+	}
+
+	return created.ID, nil
+}
+
+// removeVolumeHelper removes a helper container, failures only leave a stopped container behind so they are ignored
+func (d *Docker) removeVolumeHelper(id string) {
+	_ = d.Client.ContainerRemove(d.Ctx, id, container.RemoveOptions{Force: true})
+}
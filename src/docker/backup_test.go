@@ -0,0 +1,161 @@
+package docker_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+// volumeArchive builds the tar stream the daemon returns when copying /volume out of a container
+func volumeArchive(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "volume/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: "volume/" + name, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func helperImagePresent(mockClient *MockDockerClient) {
+	mockClient.On("ImageInspectWithRaw", mock.Anything, "busybox:1.36").Return(types.ImageInspect{}, nil)
+}
+
+func TestBackupVolume(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+	dir := t.TempDir()
+	archive := volumeArchive(t, map[string]string{"PG_VERSION": "16\n"})
+
+	helperImagePresent(mockClient)
+	mockClient.On("VolumeInspect", mock.Anything, "pgdata").Return(volume.Volume{Name: "pgdata"}, nil)
+	mockClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.MatchedBy(func(hc *container.HostConfig) bool {
+		return len(hc.Mounts) == 1 && hc.Mounts[0].Source == "pgdata" && hc.Mounts[0].ReadOnly
+	}), mock.Anything, mock.Anything, "").Return(container.CreateResponse{ID: "helper"}, nil)
+	mockClient.On("CopyFromContainer", mock.Anything, "helper", "/volume").Return(io.NopCloser(bytes.NewReader(archive)), types.ContainerPathStat{}, nil)
+	mockClient.On("ContainerRemove", mock.Anything, "helper", container.RemoveOptions{Force: true}).Return(nil)
+
+	backup, err := d.BackupVolume("pgdata", dir)
+	require.NoError(t, err)
+	assert.Equal(t, dir, filepath.Dir(backup.Path))
+	assert.True(t, strings.HasPrefix(filepath.Base(backup.Path), "pgdata-"))
+	assert.Len(t, backup.Checksum, 64)
+
+	f, err := os.Open(backup.Path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	content, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, archive, content)
+
+	checksum, err := docker.VerifyVolumeBackup(backup.Path)
+	require.NoError(t, err)
+	assert.Equal(t, backup.Checksum, checksum)
+	mockClient.AssertCalled(t, "ContainerRemove", mock.Anything, "helper", container.RemoveOptions{Force: true})
+}
+
+// failingReader returns its content and then an error, like a copy interrupted by the daemon
+type failingReader struct {
+	io.Reader
+}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func TestBackupVolumeLeavesNoPartialArchive(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+	dir := t.TempDir()
+	archive := volumeArchive(t, map[string]string{"PG_VERSION": "16\n"})
+
+	helperImagePresent(mockClient)
+	mockClient.On("VolumeInspect", mock.Anything, "pgdata").Return(volume.Volume{Name: "pgdata"}, nil)
+	mockClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, "").Return(container.CreateResponse{ID: "helper"}, nil)
+	mockClient.On("CopyFromContainer", mock.Anything, "helper", "/volume").Return(io.NopCloser(failingReader{bytes.NewReader(archive)}), types.ContainerPathStat{}, nil)
+	mockClient.On("ContainerRemove", mock.Anything, "helper", container.RemoveOptions{Force: true}).Return(nil)
+
+	_, err := d.BackupVolume("pgdata", dir)
+	assert.ErrorContains(t, err, "connection reset")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+// writeBackup writes an archive and a checksum file for it, checksum overrides the real sha256 when set
+func writeBackup(t *testing.T, content []byte, checksum string) string {
+	archivePath := filepath.Join(t.TempDir(), "pgdata.tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, content, 0644))
+	if checksum == "" {
+		sum := sha256.Sum256(content)
+		checksum = hex.EncodeToString(sum[:])
+	}
+	require.NoError(t, os.WriteFile(archivePath+".sha256", []byte(checksum+"  pgdata.tar.gz\n"), 0644))
+	return archivePath
+}
+
+func TestRestoreVolume(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+	archivePath := writeBackup(t, []byte("archive"), "")
+
+	statusCh := make(chan container.WaitResponse, 1)
+	statusCh <- container.WaitResponse{StatusCode: 0}
+
+	helperImagePresent(mockClient)
+	mockClient.On("VolumeInspect", mock.Anything, "pgdata").Return(volume.Volume{Name: "pgdata"}, nil)
+	mockClient.On("ContainerCreate", mock.Anything, mock.MatchedBy(func(config *container.Config) bool {
+		return config.Cmd[0] == "find"
+	}), mock.MatchedBy(func(hc *container.HostConfig) bool {
+		return hc.Mounts[0].Source == "pgdata" && !hc.Mounts[0].ReadOnly
+	}), mock.Anything, mock.Anything, "").Return(container.CreateResponse{ID: "helper"}, nil)
+	mockClient.On("ContainerStart", mock.Anything, "helper", mock.Anything).Return(nil)
+	mockClient.On("ContainerWait", mock.Anything, "helper", container.WaitConditionNotRunning).Return(statusCh, make(chan error))
+	var restored []byte
+	mockClient.On("CopyToContainer", mock.Anything, "helper", "/", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		restored, _ = io.ReadAll(args.Get(3).(io.Reader))
+	}).Return(nil)
+	mockClient.On("ContainerRemove", mock.Anything, "helper", container.RemoveOptions{Force: true}).Return(nil)
+
+	require.NoError(t, d.RestoreVolume("pgdata", archivePath))
+	assert.Equal(t, []byte("archive"), restored)
+	mockClient.AssertCalled(t, "ContainerRemove", mock.Anything, "helper", container.RemoveOptions{Force: true})
+}
+
+func TestRestoreVolumeChecksumMismatch(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+	archivePath := writeBackup(t, []byte("archive"), strings.Repeat("0", 64))
+
+	err := d.RestoreVolume("pgdata", archivePath)
+	assert.ErrorContains(t, err, "checksum mismatch")
+	mockClient.AssertNotCalled(t, "ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,149 @@
+package docker_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+// versionHandler answers the version endpoint and records the request path
+func versionHandler(paths *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*paths = append(*paths, r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Api-Version", "1.45")
+		_, _ = w.Write([]byte(`{"Version":"27.0.2","ApiVersion":"1.45"}`))
+	}
+}
+
+// writeTLSFiles writes a client certificate and the test server CA to a temp dir
+func writeTLSFiles(t *testing.T, server *httptest.Server) (certFile, keyFile, caFile string) {
+	dir := t.TempDir()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	caFile = filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+
+	return certFile, keyFile, caFile
+}
+
+func TestNewClientWithHostAndTLS(t *testing.T) {
+	var paths []string
+	server := httptest.NewTLSServer(versionHandler(&paths))
+	defer server.Close()
+
+	// Options must win over the environment, the old options silently used DOCKER_HOST when WithTLS was given
+	t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:1")
+
+	certFile, keyFile, caFile := writeTLSFiles(t, server)
+	cli, err := docker.NewClient(
+		docker.WithHost("tcp://"+server.Listener.Addr().String()),
+		docker.WithTLS(certFile, keyFile, caFile),
+		docker.WithAPIVersion("1.45"),
+		docker.WithTimeout(5*time.Second),
+	)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	assert.Equal(t, "tcp://"+server.Listener.Addr().String(), cli.DaemonHost())
+	assert.Equal(t, "1.45", cli.ClientVersion())
+
+	version, err := cli.ServerVersion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "27.0.2", version.Version)
+	assert.Equal(t, []string{"/v1.45/version"}, paths)
+}
+
+func TestNewClientOptionOrderDoesNotMatter(t *testing.T) {
+	var paths []string
+	server := httptest.NewTLSServer(versionHandler(&paths))
+	defer server.Close()
+
+	certFile, keyFile, caFile := writeTLSFiles(t, server)
+	cli, err := docker.NewClient(
+		docker.WithTLS(certFile, keyFile, caFile),
+		docker.WithHost("tcp://"+server.Listener.Addr().String()),
+	)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	_, err = cli.ServerVersion(context.Background())
+	require.NoError(t, err)
+	// The API version is negotiated with a ping first since it was not pinned
+	assert.Equal(t, []string{"/_ping", "/v1.45/version"}, paths)
+}
+
+func TestNewClientWithDialContext(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(versionHandler(&paths))
+	defer server.Close()
+
+	var dialed []string
+	dialer := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return (&net.Dialer{}).DialContext(ctx, "tcp", server.Listener.Addr().String())
+	}
+
+	transport := &http.Transport{}
+	httpClient := &http.Client{Transport: transport}
+	cli, err := docker.NewClient(
+		docker.WithHost("tcp://docker.internal:2375"),
+		docker.WithDialContext(dialer),
+		docker.WithHTTPClient(httpClient),
+		docker.WithAPIVersion("1.45"),
+		docker.WithTimeout(5*time.Second),
+	)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	_, err = cli.ServerVersion(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, dialed)
+	assert.True(t, strings.HasPrefix(dialed[0], "docker.internal:2375"))
+	assert.Equal(t, []string{"/v1.45/version"}, paths)
+
+	// The caller's client and transport are left untouched
+	assert.Zero(t, httpClient.Timeout)
+	assert.Same(t, transport, httpClient.Transport)
+	assert.Nil(t, transport.DialContext)
+}
+
+func TestNewClientWithInvalidTLSFiles(t *testing.T) {
+	_, err := docker.NewClient(docker.WithTLS("missing-cert.pem", "missing-key.pem", "missing-ca.pem"))
+	assert.ErrorContains(t, err, "could not apply option")
+}
@@ -0,0 +1,547 @@
+package docker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/go-connections/nat"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Labels set on compose objects, they are the same keys the compose CLI uses so it can list projects deployed by this package
+const (
+	ComposeProjectLabel    = "com.docker.compose.project"
+	ComposeServiceLabel    = "com.docker.compose.service"
+	ComposeConfigHashLabel = "com.docker.compose.config-hash"
+	ComposeNetworkLabel    = "com.docker.compose.network"
+	ComposeVolumeLabel     = "com.docker.compose.volume"
+
+	composeOneoffLabel          = "com.docker.compose.oneoff"
+	composeContainerNumberLabel = "com.docker.compose.container-number"
+)
+
+// defaultDependencyTimeout is how long ComposeUp waits for a depends_on condition when DependencyTimeout is zero
+const defaultDependencyTimeout = 5 * time.Minute
+
+// ComposeUpOptions changes how ComposeUp treats existing containers
+type ComposeUpOptions struct {
+	// ForceRecreate recreates containers even if their configuration didn't change
+	ForceRecreate bool
+	// RemoveOrphans removes project containers of services that are no longer in the compose file
+	RemoveOrphans bool
+	// DependencyTimeout is how long to wait for a service_healthy or service_completed_successfully dependency, defaults to 5 minutes
+	DependencyTimeout time.Duration
+	// Pull pulls every image before deploying so services whose tag moved to a new image are recreated, by default only missing images are pulled
+	Pull bool
+}
+
+// ComposeUp creates the project networks and volumes, then creates and starts services in dependency order
+// Services whose configuration or local image changed since the last deploy are recreated, unchanged ones are only started
+// A service is only started once its dependencies meet their depends_on condition
+func (d *Docker) ComposeUp(project *ComposeProject, opts ComposeUpOptions) error {
+	order, err := project.ServiceOrder()
+	if err != nil {
+		return err
+	}
+	if err := d.checkHomeMounts(project, order); err != nil {
+		return err
+	}
+
+	for _, key := range project.usedNetworks() {
+		if err := d.ensureComposeNetwork(project, key); err != nil {
+			return err
+		}
+	}
+
+	volumeKeys := make([]string, 0, len(project.Volumes))
+	for key := range project.Volumes {
+		volumeKeys = append(volumeKeys, key)
+	}
+	sort.Strings(volumeKeys)
+	for _, key := range volumeKeys {
+		if err := d.ensureComposeVolume(project, key); err != nil {
+			return err
+		}
+	}
+
+	existing, err := d.composeContainers(project.Name)
+	if err != nil {
+		return err
+	}
+
+	containerIDs := make(map[string]string, len(order))
+	for _, name := range order {
+		if err := d.waitForDependencies(project, name, containerIDs, opts.DependencyTimeout); err != nil {
+			return fmt.Errorf("could not start service %s: %w", name, err)
+		}
+		id, err := d.upService(project, name, existing[name], opts)
+		if err != nil {
+			return fmt.Errorf("could not start service %s: %w", name, err)
+		}
+		containerIDs[name] = id
+	}
+
+	if opts.RemoveOrphans {
+		for service, containers := range existing {
+			if _, ok := project.Services[service]; ok {
+				continue
+			}
+			for _, c := range containers {
+				if err := d.removeContainer(c.ID); err != nil {
+					return fmt.Errorf("could not remove orphan container of service %s: %w", service, err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ComposeDown stops and removes project containers in reverse dependency order, then removes project networks
+// Named volumes are only removed when removeVolumes is set, external networks and volumes are never removed
+func (d *Docker) ComposeDown(project *ComposeProject, removeVolumes bool) error {
+	order, err := project.ServiceOrder()
+	if err != nil {
+		return err
+	}
+
+	existing, err := d.composeContainers(project.Name)
+	if err != nil {
+		return err
+	}
+
+	// Orphans go first since nothing in the compose file can depend on them
+	for service, containers := range existing {
+		if _, ok := project.Services[service]; ok {
+			continue
+		}
+		for _, c := range containers {
+			if err := d.removeContainer(c.ID); err != nil {
+				return err
+			}
+		}
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		for _, c := range existing[order[i]] {
+			if err := d.removeContainer(c.ID); err != nil {
+				return fmt.Errorf("could not remove container of service %s: %w", order[i], err)
+			}
+		}
+	}
+
+	for _, key := range project.usedNetworks() {
+		if project.Networks[key].External {
+			continue
+		}
+		if err := d.RemoveNetwork(project.networkName(key)); err != nil {
+			return err
+		}
+	}
+
+	if !removeVolumes {
+		return nil
+	}
+	for key, v := range project.Volumes {
+		if v.External {
+			continue
+		}
+		if err := d.RemoveVolume(project.volumeName(key), false); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkHomeMounts rejects bind mounts starting with ~ when the daemon is not local
+// They are expanded with the home directory of this machine, which is the wrong directory on a remote host
+func (d *Docker) checkHomeMounts(project *ComposeProject, services []string) error {
+	var specs []string
+	for _, name := range services {
+		for _, spec := range project.Services[name].Volumes {
+			if strings.HasPrefix(spec, "~") {
+				specs = append(specs, name+": "+spec)
+			}
+		}
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+
+	host := d.Client.DaemonHost()
+	if strings.HasPrefix(host, "unix://") || strings.HasPrefix(host, "npipe://") {
+		return nil
+	}
+	return fmt.Errorf("bind mounts relative to ~ are not supported with the remote daemon %s, use absolute paths: %s", host, strings.Join(specs, ", "))
+}
+
+// waitForDependencies blocks until the dependencies of a service meet their condition, containerIDs holds the started dependencies
+func (d *Docker) waitForDependencies(project *ComposeProject, name string, containerIDs map[string]string, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = defaultDependencyTimeout
+	}
+
+	service := project.Services[name]
+	for _, dep := range service.DependsOn.names() {
+		switch service.DependsOn[dep] {
+		case DependencyHealthy:
+			if _, err := d.WaitForHealthy(containerIDs[dep], timeout); err != nil {
+				return fmt.Errorf("dependency %s did not become healthy: %w", dep, err)
+			}
+		case DependencyCompleted:
+			if err := d.waitForCompletion(containerIDs[dep], timeout); err != nil {
+				return fmt.Errorf("dependency %s did not complete successfully: %w", dep, err)
+			}
+		}
+	}
+	return nil
+}
+
+// waitForCompletion waits for a container to exit and returns an error unless its exit code is 0
+func (d *Docker) waitForCompletion(containerID string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(d.baseContext(), timeout)
+	defer cancel()
+
+	statusCh, errCh := d.Client.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("could not wait for container %s: %w", containerID, err)
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			return fmt.Errorf("container %s exited with code %d", containerID, status.StatusCode)
+		}
+	}
+	return nil
+}
+
+// upService creates or recreates the container of a single service, makes sure it is running and returns its ID
+func (d *Docker) upService(project *ComposeProject, name string, existing []types.Container, opts ComposeUpOptions) (string, error) {
+	service := project.Services[name]
+	imageID, err := d.serviceImageID(service.Image, opts.Pull)
+	if err != nil {
+		return "", err
+	}
+	hash, err := serviceConfigHash(project.Name, service, imageID)
+	if err != nil {
+		return "", err
+	}
+
+	// A single container runs per service, any extra containers are leftovers and get removed
+	reuse := len(existing) > 0 && !opts.ForceRecreate && existing[0].Labels[ComposeConfigHashLabel] == hash
+	stale := existing
+	if reuse {
+		stale = existing[1:]
+	}
+	for _, c := range stale {
+		if err := d.removeContainer(c.ID); err != nil {
+			return "", err
+		}
+	}
+	if reuse {
+		if existing[0].State == "running" {
+			return existing[0].ID, nil
+		}
+		return existing[0].ID, d.Client.ContainerStart(d.Ctx, existing[0].ID, container.StartOptions{})
+	}
+
+	config, hostConfig, networkingConfig, err := project.containerConfig(name, hash)
+	if err != nil {
+		return "", err
+	}
+
+	created, err := d.Client.ContainerCreate(d.Ctx, config, hostConfig, networkingConfig, nil, project.containerName(name))
+	if err != nil {
+		return "", fmt.Errorf("could not create container: %w", err)
+	}
+
+	// Docker only accepts a single network on create, the remaining ones are connected afterwards
+	networks := project.serviceNetworks(service)
+	for _, key := range networks[1:] {
+		err := d.Client.NetworkConnect(d.Ctx, project.networkName(key), created.ID, &network.EndpointSettings{
+			Aliases: []string{name},
+		})
+		if err != nil {
+			return "", fmt.Errorf("could not connect container to network %s: %w", project.networkName(key), err)
+		}
+	}
+
+	return created.ID, d.Client.ContainerStart(d.Ctx, created.ID, container.StartOptions{})
+}
+
+// serviceImageID makes sure the image of a service exists locally and returns its ID, with pull the image is always pulled first
+func (d *Docker) serviceImageID(imageRef string, pull bool) (string, error) {
+	if pull {
+		if err := d.PullDockerImage(imageRef); err != nil {
+			return "", err
+		}
+	} else if err := d.ensureImage(imageRef); err != nil {
+		return "", err
+	}
+
+	inspect, _, err := d.Client.ImageInspectWithRaw(d.Ctx, imageRef)
+	if err != nil {
+		return "", fmt.Errorf("could not inspect image %s: %w", imageRef, err)
+	}
+	return inspect.ID, nil
+}
+
+// composeContainers returns the containers of a project grouped by service name
+func (d *Docker) composeContainers(projectName string) (map[string][]types.Container, error) {
+	containers, err := d.Client.ContainerList(d.Ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", ComposeProjectLabel+"="+projectName)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list project containers: %w", err)
+	}
+
+	byService := make(map[string][]types.Container)
+	for _, c := range containers {
+		service := c.Labels[ComposeServiceLabel]
+		byService[service] = append(byService[service], c)
+	}
+
+	return byService, nil
+}
+
+func (d *Docker) removeContainer(id string) error {
+	if err := d.Client.ContainerStop(d.Ctx, id, container.StopOptions{}); err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("could not stop container %s: %w", id, err)
+	}
+	if err := d.Client.ContainerRemove(d.Ctx, id, container.RemoveOptions{}); err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("could not remove container %s: %w", id, err)
+	}
+	return nil
+}
+
+func (d *Docker) ensureComposeNetwork(project *ComposeProject, key string) error {
+	n := project.Networks[key]
+	name := project.networkName(key)
+
+	if n.External {
+		_, err := d.InspectNetwork(name)
+		if errdefs.IsNotFound(err) {
+			return fmt.Errorf("external network %s does not exist", name)
+		}
+		return err
+	}
+
+	labels := map[string]string{
+		ComposeProjectLabel: project.Name,
+		ComposeNetworkLabel: key,
+	}
+	for k, v := range n.Labels {
+		labels[k] = v
+	}
+	_, err := d.EnsureNetwork(name, NetworkOptions{
+		Driver:   n.Driver,
+		Internal: n.Internal,
+		Options:  n.DriverOpts,
+		Labels:   labels,
+	})
+	return err
+}
+
+func (d *Docker) ensureComposeVolume(project *ComposeProject, key string) error {
+	v := project.Volumes[key]
+	name := project.volumeName(key)
+
+	if v.External {
+		_, err := d.Client.VolumeInspect(d.Ctx, name)
+		if errdefs.IsNotFound(err) {
+			return fmt.Errorf("external volume %s does not exist", name)
+		}
+		if err != nil {
+			return fmt.Errorf("could not inspect volume %s: %w", name, err)
+		}
+		return nil
+	}
+
+	labels := map[string]string{
+		ComposeProjectLabel: project.Name,
+		ComposeVolumeLabel:  key,
+	}
+	for k, val := range v.Labels {
+		labels[k] = val
+	}
+	_, err := d.EnsureVolume(name, VolumeOptions{
+		Driver:     v.Driver,
+		DriverOpts: v.DriverOpts,
+		Labels:     labels,
+	})
+	return err
+}
+
+// containerName returns container_name if set, otherwise the <project>-<service>-1 name the compose CLI uses
+func (p *ComposeProject) containerName(service string) string {
+	if name := p.Services[service].ContainerName; name != "" {
+		return name
+	}
+	return p.Name + "-" + service + "-1"
+}
+
+// containerConfig converts a compose service into docker container create parameters
+func (p *ComposeProject) containerConfig(name, hash string) (*container.Config, *container.HostConfig, *network.NetworkingConfig, error) {
+	service := p.Services[name]
+
+	exposedPorts, portBindings, err := nat.ParsePortSpecs(service.Ports)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid ports: %w", err)
+	}
+
+	mounts := make([]mount.Mount, 0, len(service.Volumes))
+	for _, spec := range service.Volumes {
+		m, err := p.serviceMount(spec)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		mounts = append(mounts, m)
+	}
+
+	restartPolicy, err := parseRestartPolicy(service.Restart)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	healthcheck, err := service.healthConfig()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	env := make([]string, 0, len(service.Environment))
+	for k, v := range service.Environment {
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+
+	labels := map[string]string{
+		ComposeProjectLabel:         p.Name,
+		ComposeServiceLabel:         name,
+		ComposeConfigHashLabel:      hash,
+		composeOneoffLabel:          "False",
+		composeContainerNumberLabel: "1",
+	}
+	for k, v := range service.Labels {
+		labels[k] = v
+	}
+
+	config := &container.Config{
+		Image:        service.Image,
+		Cmd:          []string(service.Command),
+		Entrypoint:   []string(service.Entrypoint),
+		Env:          env,
+		Labels:       labels,
+		ExposedPorts: exposedPorts,
+		User:         service.User,
+		WorkingDir:   service.WorkingDir,
+		Hostname:     service.Hostname,
+		Healthcheck:  healthcheck,
+	}
+	hostConfig := &container.HostConfig{
+		PortBindings:  portBindings,
+		Mounts:        mounts,
+		RestartPolicy: restartPolicy,
+	}
+	firstNetwork := p.serviceNetworks(service)[0]
+	networkingConfig := &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			p.networkName(firstNetwork): {Aliases: []string{name}},
+		},
+	}
+
+	return config, hostConfig, networkingConfig, nil
+}
+
+// serviceMount parses the short volume syntax: named volumes, relative or absolute bind mounts and anonymous volumes
+func (p *ComposeProject) serviceMount(spec string) (mount.Mount, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) == 1 {
+		return mount.Mount{Type: mount.TypeVolume, Target: parts[0]}, nil
+	}
+	if len(parts) > 3 {
+		return mount.Mount{}, fmt.Errorf("invalid volume %q", spec)
+	}
+
+	m := mount.Mount{Target: parts[1]}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			m.ReadOnly = true
+		case "rw":
+		default:
+			return mount.Mount{}, fmt.Errorf("unsupported volume mode %q in %q", parts[2], spec)
+		}
+	}
+
+	source := parts[0]
+	switch {
+	case filepath.IsAbs(source):
+		m.Type = mount.TypeBind
+		m.Source = source
+	case strings.HasPrefix(source, "."):
+		m.Type = mount.TypeBind
+		m.Source = filepath.Join(p.WorkingDir, source)
+	case strings.HasPrefix(source, "~"):
+		// Expanded on this machine, ComposeUp rejects these mounts for remote daemons
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return mount.Mount{}, fmt.Errorf("could not resolve %q: %w", spec, err)
+		}
+		m.Type = mount.TypeBind
+		m.Source = filepath.Join(home, strings.TrimPrefix(source, "~"))
+	default:
+		if _, ok := p.Volumes[source]; !ok {
+			return mount.Mount{}, fmt.Errorf("undefined volume %s", source)
+		}
+		m.Type = mount.TypeVolume
+		m.Source = p.volumeName(source)
+	}
+
+	return m, nil
+}
+
+func parseRestartPolicy(restart string) (container.RestartPolicy, error) {
+	if restart == "" {
+		return container.RestartPolicy{}, nil
+	}
+
+	name, retries, hasRetries := strings.Cut(restart, ":")
+	policy := container.RestartPolicy{Name: container.RestartPolicyMode(name)}
+	if hasRetries {
+		count, err := strconv.Atoi(retries)
+		if err != nil {
+			return policy, fmt.Errorf("invalid restart policy %q: %w", restart, err)
+		}
+		policy.MaximumRetryCount = count
+	}
+
+	return policy, nil
+}
+
+// serviceConfigHash hashes the service definition and its image ID so changed services can be detected on the next ComposeUp
+// The image ID makes a tag that moved to another image count as a change
+func serviceConfigHash(projectName string, service ComposeService, imageID string) (string, error) {
+	data, err := json.Marshal(struct {
+		Project string         `json:"project"`
+		Service ComposeService `json:"service"`
+		ImageID string         `json:"image_id"`
+	}{projectName, service, imageID})
+	if err != nil {
+		return "", fmt.Errorf("could not hash service configuration: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
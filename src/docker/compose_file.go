@@ -0,0 +1,495 @@
+package docker
+
+import (
+	"fmt"
+	"github.com/docker/docker/api/types/container"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultComposeNetwork is the network services join when they don't list any networks
+const defaultComposeNetwork = "default"
+
+// Conditions of depends_on entries, ComposeUp waits for them before starting the dependent service
+const (
+	DependencyStarted   = "service_started"
+	DependencyHealthy   = "service_healthy"
+	DependencyCompleted = "service_completed_successfully"
+)
+
+var invalidProjectNameChars = regexp.MustCompile(`[^a-z0-9_-]+`)
+
+// ComposeProject is a parsed docker-compose.yml, only the fields needed to run containers on a single host are supported
+type ComposeProject struct {
+	Name       string                    `yaml:"name"`
+	WorkingDir string                    `yaml:"-"`
+	Services   map[string]ComposeService `yaml:"services"`
+	Networks   map[string]ComposeNetwork `yaml:"networks"`
+	Volumes    map[string]ComposeVolume  `yaml:"volumes"`
+}
+
+type ComposeService struct {
+	Image         string              `yaml:"image" json:"image"`
+	ContainerName string              `yaml:"container_name" json:"container_name,omitempty"`
+	Command       composeCommand      `yaml:"command" json:"command,omitempty"`
+	Entrypoint    composeCommand      `yaml:"entrypoint" json:"entrypoint,omitempty"`
+	Environment   composeMapping      `yaml:"environment" json:"environment,omitempty"`
+	Labels        composeMapping      `yaml:"labels" json:"labels,omitempty"`
+	Ports         []string            `yaml:"ports" json:"ports,omitempty"`
+	Volumes       []string            `yaml:"volumes" json:"volumes,omitempty"`
+	Networks      composeNames        `yaml:"networks" json:"networks,omitempty"`
+	DependsOn     composeDependsOn    `yaml:"depends_on" json:"depends_on,omitempty"`
+	Restart       string              `yaml:"restart" json:"restart,omitempty"`
+	User          string              `yaml:"user" json:"user,omitempty"`
+	WorkingDir    string              `yaml:"working_dir" json:"working_dir,omitempty"`
+	Hostname      string              `yaml:"hostname" json:"hostname,omitempty"`
+	Healthcheck   *ComposeHealthcheck `yaml:"healthcheck" json:"healthcheck,omitempty"`
+}
+
+type ComposeHealthcheck struct {
+	Test        composeHealthTest `yaml:"test" json:"test,omitempty"`
+	Interval    string            `yaml:"interval" json:"interval,omitempty"`
+	Timeout     string            `yaml:"timeout" json:"timeout,omitempty"`
+	StartPeriod string            `yaml:"start_period" json:"start_period,omitempty"`
+	Retries     int               `yaml:"retries" json:"retries,omitempty"`
+	Disable     bool              `yaml:"disable" json:"disable,omitempty"`
+}
+
+type ComposeNetwork struct {
+	Name       string            `yaml:"name"`
+	Driver     string            `yaml:"driver"`
+	DriverOpts map[string]string `yaml:"driver_opts"`
+	Internal   bool              `yaml:"internal"`
+	External   bool              `yaml:"external"`
+	Labels     composeMapping    `yaml:"labels"`
+}
+
+type ComposeVolume struct {
+	Name       string            `yaml:"name"`
+	Driver     string            `yaml:"driver"`
+	DriverOpts map[string]string `yaml:"driver_opts"`
+	External   bool              `yaml:"external"`
+	Labels     composeMapping    `yaml:"labels"`
+}
+
+// composeCommand accepts both the string and the list form of command and entrypoint
+type composeCommand []string
+
+func (c *composeCommand) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		words, err := splitCommand(value.Value)
+		if err != nil {
+			return err
+		}
+		*c = words
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*c = list
+	return nil
+}
+
+// composeHealthTest accepts a plain string which compose runs with the container shell
+type composeHealthTest []string
+
+func (t *composeHealthTest) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*t = []string{"CMD-SHELL", value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// composeMapping accepts both the map and the KEY=VALUE list form used by environment and labels
+type composeMapping map[string]string
+
+func (m *composeMapping) UnmarshalYAML(value *yaml.Node) error {
+	result := make(map[string]string)
+	switch value.Kind {
+	case yaml.SequenceNode:
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		for _, item := range list {
+			k, v, _ := strings.Cut(item, "=")
+			result[k] = v
+		}
+	case yaml.MappingNode:
+		// Mapping node content alternates between keys and values
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			k, v := value.Content[i], value.Content[i+1]
+			if v.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: value of %s must be a scalar", v.Line, k.Value)
+			}
+			if v.Tag == "!!null" {
+				result[k.Value] = ""
+				continue
+			}
+			result[k.Value] = v.Value
+		}
+	default:
+		return fmt.Errorf("line %d: expected a map or a list", value.Line)
+	}
+	*m = result
+	return nil
+}
+
+// composeNames accepts both the list and the map form used by service networks, only the keys are kept
+type composeNames []string
+
+func (n *composeNames) UnmarshalYAML(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.SequenceNode:
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		*n = list
+	case yaml.MappingNode:
+		names := make([]string, 0, len(value.Content)/2)
+		for i := 0; i < len(value.Content); i += 2 {
+			names = append(names, value.Content[i].Value)
+		}
+		sort.Strings(names)
+		*n = names
+	default:
+		return fmt.Errorf("line %d: expected a map or a list", value.Line)
+	}
+	return nil
+}
+
+// composeDependsOn maps each dependency of a service to its condition
+// It accepts the list form and the map form of depends_on, the list form and entries without a condition use service_started
+type composeDependsOn map[string]string
+
+func (d *composeDependsOn) UnmarshalYAML(value *yaml.Node) error {
+	deps := composeDependsOn{}
+	switch value.Kind {
+	case yaml.SequenceNode:
+		var list []string
+		if err := value.Decode(&list); err != nil {
+			return err
+		}
+		for _, name := range list {
+			deps[name] = DependencyStarted
+		}
+	case yaml.MappingNode:
+		var entries map[string]struct {
+			Condition string `yaml:"condition"`
+		}
+		if err := value.Decode(&entries); err != nil {
+			return err
+		}
+		for name, entry := range entries {
+			deps[name] = entry.Condition
+			if entry.Condition == "" {
+				deps[name] = DependencyStarted
+			}
+		}
+	default:
+		return fmt.Errorf("line %d: expected a map or a list", value.Line)
+	}
+	*d = deps
+	return nil
+}
+
+// names returns the dependencies sorted by name
+func (d composeDependsOn) names() []string {
+	names := make([]string, 0, len(d))
+	for name := range d {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadComposeFile parses a compose file, projectName overrides the name in the file and falls back to the file directory name
+func LoadComposeFile(path, projectName string) (*ComposeProject, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read compose file: %w", err)
+	}
+
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var project ComposeProject
+	if err := yaml.Unmarshal([]byte(interpolate(string(data))), &project); err != nil {
+		return nil, fmt.Errorf("could not parse compose file %s: %w", path, err)
+	}
+
+	project.WorkingDir = filepath.Dir(absPath)
+	if projectName != "" {
+		project.Name = projectName
+	}
+	if project.Name == "" {
+		project.Name = filepath.Base(project.WorkingDir)
+	}
+	project.Name = invalidProjectNameChars.ReplaceAllString(strings.ToLower(project.Name), "")
+	if project.Name == "" {
+		return nil, fmt.Errorf("could not derive a valid project name for %s", path)
+	}
+
+	if err := project.validate(); err != nil {
+		return nil, err
+	}
+
+	return &project, nil
+}
+
+func (p *ComposeProject) validate() error {
+	if len(p.Services) == 0 {
+		return fmt.Errorf("compose project %s has no services", p.Name)
+	}
+
+	for name, service := range p.Services {
+		if service.Image == "" {
+			return fmt.Errorf("service %s has no image, building images is not supported", name)
+		}
+		for _, dep := range service.DependsOn.names() {
+			depService, ok := p.Services[dep]
+			if !ok {
+				return fmt.Errorf("service %s depends on undefined service %s", name, dep)
+			}
+			switch condition := service.DependsOn[dep]; condition {
+			case DependencyStarted, DependencyCompleted:
+			case DependencyHealthy:
+				if depService.Healthcheck == nil || depService.Healthcheck.Disable {
+					return fmt.Errorf("service %s waits for %s to be healthy but %s has no healthcheck", name, dep, dep)
+				}
+			default:
+				return fmt.Errorf("service %s depends on %s with unsupported condition %q", name, dep, condition)
+			}
+		}
+		for _, network := range service.Networks {
+			if _, ok := p.Networks[network]; !ok && network != defaultComposeNetwork {
+				return fmt.Errorf("service %s uses undefined network %s", name, network)
+			}
+		}
+		for _, spec := range service.Volumes {
+			if _, err := p.serviceMount(spec); err != nil {
+				return fmt.Errorf("service %s: %w", name, err)
+			}
+		}
+		if _, err := service.healthConfig(); err != nil {
+			return fmt.Errorf("service %s: %w", name, err)
+		}
+	}
+
+	_, err := p.ServiceOrder()
+	return err
+}
+
+// ServiceOrder returns service names sorted so that every service comes after the services it depends on
+func (p *ComposeProject) ServiceOrder() ([]string, error) {
+	names := make([]string, 0, len(p.Services))
+	for name := range p.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(names))
+	order := make([]string, 0, len(names))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle between services: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		for _, dep := range p.Services[name].DependsOn.names() {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order = append(order, name)
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// networkName returns the docker network name of a network key, project networks are prefixed with the project name
+func (p *ComposeProject) networkName(key string) string {
+	n := p.Networks[key]
+	if n.Name != "" {
+		return n.Name
+	}
+	if n.External {
+		return key
+	}
+	return p.Name + "_" + key
+}
+
+// volumeName returns the docker volume name of a volume key, project volumes are prefixed with the project name
+func (p *ComposeProject) volumeName(key string) string {
+	v := p.Volumes[key]
+	if v.Name != "" {
+		return v.Name
+	}
+	if v.External {
+		return key
+	}
+	return p.Name + "_" + key
+}
+
+// serviceNetworks returns the network keys of a service, services without networks join the default network
+func (p *ComposeProject) serviceNetworks(service ComposeService) []string {
+	if len(service.Networks) == 0 {
+		return []string{defaultComposeNetwork}
+	}
+	return service.Networks
+}
+
+// usedNetworks returns the keys of all networks used by services, including the implicit default network
+func (p *ComposeProject) usedNetworks() []string {
+	used := make(map[string]bool)
+	for _, service := range p.Services {
+		for _, network := range p.serviceNetworks(service) {
+			used[network] = true
+		}
+	}
+	keys := make([]string, 0, len(used))
+	for key := range used {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// healthConfig converts the compose healthcheck into the container healthcheck, nil keeps the image healthcheck
+func (s ComposeService) healthConfig() (*container.HealthConfig, error) {
+	if s.Healthcheck == nil {
+		return nil, nil
+	}
+	if s.Healthcheck.Disable {
+		return &container.HealthConfig{Test: []string{"NONE"}}, nil
+	}
+
+	h := &container.HealthConfig{
+		Test:    s.Healthcheck.Test,
+		Retries: s.Healthcheck.Retries,
+	}
+	for _, d := range []struct {
+		value  string
+		target *time.Duration
+		field  string
+	}{
+		{s.Healthcheck.Interval, &h.Interval, "interval"},
+		{s.Healthcheck.Timeout, &h.Timeout, "timeout"},
+		{s.Healthcheck.StartPeriod, &h.StartPeriod, "start_period"},
+	} {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid healthcheck %s %q: %w", d.field, d.value, err)
+		}
+		*d.target = parsed
+	}
+
+	return h, nil
+}
+
+// interpolate replaces ${VAR}, ${VAR:-default} and ${VAR-default} with environment values, $$ escapes a dollar sign
+func interpolate(s string) string {
+	return os.Expand(s, func(key string) string {
+		if key == "$" {
+			return "$"
+		}
+		if name, def, ok := strings.Cut(key, ":-"); ok {
+			if v := os.Getenv(name); v != "" {
+				return v
+			}
+			return def
+		}
+		if name, def, ok := strings.Cut(key, "-"); ok {
+			if v, set := os.LookupEnv(name); set {
+				return v
+			}
+			return def
+		}
+		return os.Getenv(key)
+	})
+}
+
+// splitCommand splits a command string into words the way a shell would, supporting quotes and backslash escapes
+func splitCommand(s string) ([]string, error) {
+	var (
+		words   []string
+		current strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in command %q", s)
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+
+	return words, nil
+}
@@ -0,0 +1,384 @@
+package docker_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+const testComposeFile = `
+services:
+  web:
+    image: "nginx:${WEB_TAG:-1.27}"
+    command: nginx -g "daemon off;"
+    ports:
+      - "8080:80"
+    environment:
+      - MODE=production
+    depends_on:
+      - api
+  api:
+    image: example/api:1.0
+    environment:
+      DB_HOST: db
+      DEBUG:
+    depends_on:
+      db:
+        condition: service_started
+    healthcheck:
+      test: curl -f http://localhost/health
+      interval: 10s
+      retries: 3
+  db:
+    image: postgres:16
+    volumes:
+      - data:/var/lib/postgresql/data
+      - ./init:/docker-entrypoint-initdb.d:ro
+volumes:
+  data:
+`
+
+func writeComposeFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	require.NoError(t, os.WriteFile(path, []byte(testComposeFile), 0600))
+	return path
+}
+
+func TestLoadComposeFile(t *testing.T) {
+	project, err := docker.LoadComposeFile(writeComposeFile(t), "Shop")
+	require.NoError(t, err)
+
+	assert.Equal(t, "shop", project.Name)
+	assert.Equal(t, "nginx:1.27", project.Services["web"].Image)
+	assert.Equal(t, []string{"nginx", "-g", "daemon off;"}, []string(project.Services["web"].Command))
+	assert.Equal(t, "production", project.Services["web"].Environment["MODE"])
+	assert.Equal(t, "db", project.Services["api"].Environment["DB_HOST"])
+	assert.Contains(t, project.Services["api"].Environment, "DEBUG")
+	assert.Equal(t, []string{"CMD-SHELL", "curl -f http://localhost/health"}, []string(project.Services["api"].Healthcheck.Test))
+	assert.Equal(t, docker.DependencyStarted, project.Services["api"].DependsOn["db"])
+	assert.Equal(t, docker.DependencyStarted, project.Services["web"].DependsOn["api"])
+
+	order, err := project.ServiceOrder()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db", "api", "web"}, order)
+}
+
+func TestLoadComposeFileRejectsCycles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+services:
+  a:
+    image: busybox
+    depends_on: [b]
+  b:
+    image: busybox
+    depends_on: [a]
+`), 0600))
+
+	_, err := docker.LoadComposeFile(path, "cycle")
+	assert.ErrorContains(t, err, "dependency cycle")
+}
+
+func TestComposeUp(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	project, err := docker.LoadComposeFile(writeComposeFile(t), "shop")
+	require.NoError(t, err)
+
+	notFound := errdefs.NotFound(errors.New("not found"))
+	mockClient.On("NetworkInspect", mock.Anything, "shop_default", mock.Anything).Return(network.Inspect{}, notFound)
+	mockClient.On("NetworkCreate", mock.Anything, "shop_default", mock.MatchedBy(func(opts network.CreateOptions) bool {
+		return opts.Labels[docker.ComposeProjectLabel] == "shop"
+	})).Return(network.CreateResponse{ID: "net1"}, nil)
+	mockClient.On("VolumeInspect", mock.Anything, "shop_data").Return(volume.Volume{}, notFound)
+	mockClient.On("VolumeCreate", mock.Anything, mock.MatchedBy(func(opts volume.CreateOptions) bool {
+		return opts.Name == "shop_data" && opts.Labels[docker.ComposeVolumeLabel] == "data"
+	})).Return(volume.Volume{Name: "shop_data"}, nil)
+	mockClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil)
+	mockClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(types.ImageInspect{}, nil)
+
+	var created []string
+	mockClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			config := args.Get(1).(*container.Config)
+			assert.Equal(t, "shop", config.Labels[docker.ComposeProjectLabel])
+			assert.NotEmpty(t, config.Labels[docker.ComposeConfigHashLabel])
+			created = append(created, args.String(5))
+		}).
+		Return(container.CreateResponse{ID: "c1"}, nil)
+	mockClient.On("ContainerStart", mock.Anything, "c1", mock.Anything).Return(nil)
+
+	require.NoError(t, d.ComposeUp(project, docker.ComposeUpOptions{}))
+	assert.Equal(t, []string{"shop-db-1", "shop-api-1", "shop-web-1"}, created)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestComposeUpRecreatesChangedService(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	project, err := docker.LoadComposeFile(writeComposeFile(t), "shop")
+	require.NoError(t, err)
+
+	// First deploy records the config hash of every service
+	hashes := make(map[string]string)
+	mockClient.On("NetworkInspect", mock.Anything, mock.Anything, mock.Anything).Return(network.Inspect{}, nil)
+	mockClient.On("VolumeInspect", mock.Anything, mock.Anything).Return(volume.Volume{}, nil)
+	mockClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(types.ImageInspect{}, nil)
+	mockClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil).Once()
+	mockClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			config := args.Get(1).(*container.Config)
+			hashes[config.Labels[docker.ComposeServiceLabel]] = config.Labels[docker.ComposeConfigHashLabel]
+		}).
+		Return(container.CreateResponse{ID: "new"}, nil)
+	mockClient.On("ContainerStart", mock.Anything, "new", mock.Anything).Return(nil)
+	require.NoError(t, d.ComposeUp(project, docker.ComposeUpOptions{}))
+
+	// Second deploy changes only the web image
+	web := project.Services["web"]
+	web.Image = "nginx:1.28"
+	project.Services["web"] = web
+
+	existing := make([]types.Container, 0, len(hashes))
+	for service, hash := range hashes {
+		existing = append(existing, types.Container{
+			ID:    service + "-old",
+			State: "running",
+			Labels: map[string]string{
+				docker.ComposeProjectLabel:    "shop",
+				docker.ComposeServiceLabel:    service,
+				docker.ComposeConfigHashLabel: hash,
+			},
+		})
+	}
+	mockClient.On("ContainerList", mock.Anything, mock.Anything).Return(existing, nil).Once()
+	mockClient.On("ContainerStop", mock.Anything, "web-old", mock.Anything).Return(nil).Once()
+	mockClient.On("ContainerRemove", mock.Anything, "web-old", mock.Anything).Return(nil).Once()
+
+	require.NoError(t, d.ComposeUp(project, docker.ComposeUpOptions{}))
+
+	mockClient.AssertNumberOfCalls(t, "ContainerCreate", 4)
+	mockClient.AssertNotCalled(t, "ContainerStop", mock.Anything, "db-old", mock.Anything)
+	mockClient.AssertExpectations(t)
+}
+
+const dependencyComposeFile = `
+services:
+  migrate:
+    image: example/api:1.0
+    command: ./manage migrate
+    depends_on:
+      db:
+        condition: service_healthy
+  api:
+    image: example/api:1.0
+    depends_on:
+      migrate:
+        condition: service_completed_successfully
+  db:
+    image: postgres:16
+    healthcheck:
+      test: pg_isready
+`
+
+func TestLoadComposeFileValidatesDependencyConditions(t *testing.T) {
+	for name, file := range map[string]string{
+		"unsupported condition \"service_ready\"": `
+services:
+  api:
+    image: example/api:1.0
+    depends_on:
+      db:
+        condition: service_ready
+  db:
+    image: postgres:16
+`,
+		"db has no healthcheck": `
+services:
+  api:
+    image: example/api:1.0
+    depends_on:
+      db:
+        condition: service_healthy
+  db:
+    image: postgres:16
+`,
+	} {
+		path := filepath.Join(t.TempDir(), "docker-compose.yml")
+		require.NoError(t, os.WriteFile(path, []byte(file), 0600))
+		_, err := docker.LoadComposeFile(path, "shop")
+		assert.ErrorContains(t, err, name)
+	}
+}
+
+func TestComposeUpWaitsForDependencyConditions(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	require.NoError(t, os.WriteFile(path, []byte(dependencyComposeFile), 0600))
+	project, err := docker.LoadComposeFile(path, "shop")
+	require.NoError(t, err)
+
+	var steps []string
+	mockClient.On("NetworkInspect", mock.Anything, mock.Anything, mock.Anything).Return(network.Inspect{}, nil)
+	mockClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil)
+	mockClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(types.ImageInspect{}, nil)
+	for _, service := range []string{"db", "migrate", "api"} {
+		mockClient.On("ContainerCreate", mock.Anything, mock.MatchedBy(func(config *container.Config) bool {
+			return config.Labels[docker.ComposeServiceLabel] == service
+		}), mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(container.CreateResponse{ID: service + "-id"}, nil)
+	}
+	mockClient.On("ContainerStart", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		steps = append(steps, "start "+args.String(1))
+	}).Return(nil)
+
+	// db becomes healthy before migrate starts, migrate exits with 0 before api starts
+	messages, errs := eventStream(events.Message{Action: events.ActionHealthStatusHealthy})
+	mockClient.On("Events", mock.Anything, mock.Anything).Return(messages, errs)
+	mockClient.On("ContainerInspect", mock.Anything, "db-id").Run(func(mock.Arguments) {
+		steps = append(steps, "healthy db-id")
+	}).Return(healthJSON(true, types.Starting), nil)
+	statusCh := make(chan container.WaitResponse, 1)
+	statusCh <- container.WaitResponse{StatusCode: 0}
+	mockClient.On("ContainerWait", mock.Anything, "migrate-id", container.WaitConditionNotRunning).Run(func(mock.Arguments) {
+		steps = append(steps, "completed migrate-id")
+	}).Return(statusCh, make(chan error))
+
+	require.NoError(t, d.ComposeUp(project, docker.ComposeUpOptions{DependencyTimeout: time.Second}))
+	assert.Equal(t, []string{"start db-id", "healthy db-id", "start migrate-id", "completed migrate-id", "start api-id"}, steps)
+}
+
+func TestComposeUpFailsWhenDependencyFails(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	require.NoError(t, os.WriteFile(path, []byte(dependencyComposeFile), 0600))
+	project, err := docker.LoadComposeFile(path, "shop")
+	require.NoError(t, err)
+	// Only keep the migrate -> api dependency
+	delete(project.Services, "db")
+	migrate := project.Services["migrate"]
+	migrate.DependsOn = nil
+	project.Services["migrate"] = migrate
+
+	mockClient.On("NetworkInspect", mock.Anything, mock.Anything, mock.Anything).Return(network.Inspect{}, nil)
+	mockClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil)
+	mockClient.On("ImageInspectWithRaw", mock.Anything, mock.Anything).Return(types.ImageInspect{}, nil)
+	mockClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(container.CreateResponse{ID: "migrate-id"}, nil).Once()
+	mockClient.On("ContainerStart", mock.Anything, "migrate-id", mock.Anything).Return(nil)
+	statusCh := make(chan container.WaitResponse, 1)
+	statusCh <- container.WaitResponse{StatusCode: 1}
+	mockClient.On("ContainerWait", mock.Anything, "migrate-id", container.WaitConditionNotRunning).Return(statusCh, make(chan error))
+
+	err = d.ComposeUp(project, docker.ComposeUpOptions{})
+	assert.ErrorContains(t, err, "could not start service api: dependency migrate did not complete successfully: container migrate-id exited with code 1")
+	mockClient.AssertNumberOfCalls(t, "ContainerCreate", 1)
+}
+
+func TestComposeUpRejectsHomeMountsOnRemoteDaemon(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+services:
+  api:
+    image: example/api:1.0
+    volumes:
+      - ~/.aws:/root/.aws:ro
+`), 0600))
+	project, err := docker.LoadComposeFile(path, "shop")
+	require.NoError(t, err)
+
+	mockClient.On("DaemonHost").Return("http://docker.example.com").Once()
+	err = d.ComposeUp(project, docker.ComposeUpOptions{})
+	assert.ErrorContains(t, err, "bind mounts relative to ~ are not supported with the remote daemon http://docker.example.com, use absolute paths: api: ~/.aws:/root/.aws:ro")
+	mockClient.AssertNotCalled(t, "NetworkInspect", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestComposeUpRecreatesServiceWhenTagMoved(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	require.NoError(t, os.WriteFile(path, []byte("services:\n  web:\n    image: nginx:latest\n"), 0600))
+	project, err := docker.LoadComposeFile(path, "shop")
+	require.NoError(t, err)
+
+	var hash string
+	mockClient.On("NetworkInspect", mock.Anything, mock.Anything, mock.Anything).Return(network.Inspect{}, nil)
+	mockClient.On("ImagePull", mock.Anything, "nginx:latest", mock.Anything).Return(io.NopCloser(strings.NewReader("")), nil)
+	mockClient.On("ImageInspectWithRaw", mock.Anything, "nginx:latest").Return(types.ImageInspect{ID: "sha256:old"}, nil).Once()
+	mockClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{}, nil).Once()
+	mockClient.On("ContainerCreate", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			hash = args.Get(1).(*container.Config).Labels[docker.ComposeConfigHashLabel]
+		}).
+		Return(container.CreateResponse{ID: "new"}, nil)
+	mockClient.On("ContainerStart", mock.Anything, "new", mock.Anything).Return(nil)
+	require.NoError(t, d.ComposeUp(project, docker.ComposeUpOptions{Pull: true}))
+
+	// The compose file didn't change but latest now points to another image
+	mockClient.On("ImageInspectWithRaw", mock.Anything, "nginx:latest").Return(types.ImageInspect{ID: "sha256:new"}, nil).Once()
+	mockClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{{
+		ID:     "web-old",
+		State:  "running",
+		Labels: map[string]string{docker.ComposeServiceLabel: "web", docker.ComposeConfigHashLabel: hash},
+	}}, nil).Once()
+	mockClient.On("ContainerStop", mock.Anything, "web-old", mock.Anything).Return(nil).Once()
+	mockClient.On("ContainerRemove", mock.Anything, "web-old", mock.Anything).Return(nil).Once()
+	require.NoError(t, d.ComposeUp(project, docker.ComposeUpOptions{Pull: true}))
+
+	mockClient.AssertNumberOfCalls(t, "ImagePull", 2)
+	mockClient.AssertNumberOfCalls(t, "ContainerCreate", 2)
+	mockClient.AssertExpectations(t)
+}
+
+func TestComposeDown(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	project, err := docker.LoadComposeFile(writeComposeFile(t), "shop")
+	require.NoError(t, err)
+
+	var removed []string
+	mockClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{
+		{ID: "db1", Labels: map[string]string{docker.ComposeServiceLabel: "db"}},
+		{ID: "web1", Labels: map[string]string{docker.ComposeServiceLabel: "web"}},
+		{ID: "api1", Labels: map[string]string{docker.ComposeServiceLabel: "api"}},
+	}, nil)
+	mockClient.On("ContainerStop", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockClient.On("ContainerRemove", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { removed = append(removed, args.String(1)) }).
+		Return(nil)
+	mockClient.On("NetworkRemove", mock.Anything, "shop_default").Return(nil)
+
+	require.NoError(t, d.ComposeDown(project, false))
+	assert.Equal(t, []string{"web1", "api1", "db1"}, removed)
+
+	mockClient.AssertNotCalled(t, "VolumeRemove", mock.Anything, mock.Anything, mock.Anything)
+	mockClient.AssertExpectations(t)
+}
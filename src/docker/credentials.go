@@ -0,0 +1,291 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/onurcevik/deploy-utilities/common/config"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// dockerHubHost is the key Docker Hub credentials are stored under, config.json uses https://index.docker.io/v1/ for the same registry
+const dockerHubHost = "docker.io"
+
+// CredentialStore holds registry credentials keyed by registry host so a single Docker object can talk to several registries
+type CredentialStore struct {
+	mu            sync.RWMutex
+	auths         map[string]registry.AuthConfig
+	helpers       map[string]string
+	providers     map[string]CredentialProvider
+	defaultHelper string
+}
+
+// CredentialProvider returns the current credentials of a registry whose credentials expire, such as ECR tokens
+type CredentialProvider func() (registry.AuthConfig, error)
+
+// dockerConfigFile is the subset of ~/.docker/config.json we read credentials from
+type dockerConfigFile struct {
+	Auths       map[string]dockerConfigAuth `json:"auths"`
+	CredsStore  string                      `json:"credsStore"`
+	CredHelpers map[string]string           `json:"credHelpers"`
+}
+
+type dockerConfigAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+	RegistryToken string `json:"registrytoken"`
+}
+
+// credentialHelperResponse is the output of `docker-credential-<helper> get`
+type credentialHelperResponse struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// NewCredentialStore creates an empty CredentialStore
+func NewCredentialStore() *CredentialStore {
+	return &CredentialStore{
+		auths:     make(map[string]registry.AuthConfig),
+		helpers:   make(map[string]string),
+		providers: make(map[string]CredentialProvider),
+	}
+}
+
+// Set stores auth under the registry host of its ServerAddress, replacing previous credentials for that host
+func (s *CredentialStore) Set(auth registry.AuthConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auths[RegistryHost(auth.ServerAddress)] = auth
+}
+
+// SetProvider makes Get ask provider for the credentials of host on every call, the credentials it returns replace the stored ones
+func (s *CredentialStore) SetProvider(host string, provider CredentialProvider) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.providers == nil {
+		s.providers = make(map[string]CredentialProvider)
+	}
+	s.providers[RegistryHost(host)] = provider
+}
+
+// Get returns the credentials for the given registry host, a provider set for the host takes precedence over stored credentials
+// Credential helpers are consulted when neither a provider nor static credentials exist
+func (s *CredentialStore) Get(host string) (registry.AuthConfig, bool, error) {
+	host = RegistryHost(host)
+
+	s.mu.RLock()
+	provider := s.providers[host]
+	s.mu.RUnlock()
+	if provider != nil {
+		auth, err := provider()
+		if err != nil {
+			return registry.AuthConfig{}, false, fmt.Errorf("could not get credentials for %s: %w", host, err)
+		}
+		s.Set(auth)
+		return auth, true, nil
+	}
+
+	s.mu.RLock()
+	auth, ok := s.auths[host]
+	helper, hasHelper := s.helpers[host]
+	if !hasHelper {
+		helper = s.defaultHelper
+	}
+	s.mu.RUnlock()
+
+	if ok {
+		return auth, true, nil
+	}
+	if helper == "" {
+		return registry.AuthConfig{}, false, nil
+	}
+
+	auth, ok, err := getFromCredentialHelper(helper, host)
+	if err != nil || !ok {
+		return registry.AuthConfig{}, false, err
+	}
+	s.Set(auth)
+
+	return auth, true, nil
+}
+
+// ForImage returns the credentials for the registry the given image reference points to
+func (s *CredentialStore) ForImage(imageRef string) (registry.AuthConfig, bool, error) {
+	host, err := ImageRegistryHost(imageRef)
+	if err != nil {
+		return registry.AuthConfig{}, false, err
+	}
+	return s.Get(host)
+}
+
+// EncodedAuthForImage returns base64 encoded credentials for the image registry, or an empty string if none are known
+func (s *CredentialStore) EncodedAuthForImage(imageRef string) (string, error) {
+	auth, ok, err := s.ForImage(imageRef)
+	if err != nil || !ok {
+		return "", err
+	}
+	return registry.EncodeAuthConfig(auth)
+}
+
+// LoadConfig adds registry credentials defined in config.Config, nothing is added when a credential is still a secret reference
+func (s *CredentialStore) LoadConfig(conf config.Config) error {
+	if err := conf.CheckResolved(); err != nil {
+		return fmt.Errorf("could not load registry credentials: %w", err)
+	}
+	for _, r := range conf.Docker.Registries {
+		s.Set(registry.AuthConfig{
+			Username:      r.Username,
+			Password:      r.Password,
+			ServerAddress: r.Server,
+		})
+	}
+	return nil
+}
+
+// LoadDockerConfig adds credentials from a docker CLI config file, an empty path reads $DOCKER_CONFIG/config.json or ~/.docker/config.json
+func (s *CredentialStore) LoadDockerConfig(path string) error {
+	if path == "" {
+		var err error
+		path, err = defaultDockerConfigPath()
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read docker config file: %w", err)
+	}
+
+	var cf dockerConfigFile
+	if err := json.Unmarshal(data, &cf); err != nil {
+		return fmt.Errorf("could not parse docker config file %s: %w", path, err)
+	}
+
+	for server, a := range cf.Auths {
+		auth := registry.AuthConfig{
+			Username:      a.Username,
+			Password:      a.Password,
+			ServerAddress: server,
+			IdentityToken: a.IdentityToken,
+			RegistryToken: a.RegistryToken,
+		}
+		if a.Auth != "" {
+			auth.Username, auth.Password, err = decodeBasicAuth(a.Auth)
+			if err != nil {
+				return fmt.Errorf("invalid auth for %s in docker config file: %w", server, err)
+			}
+		}
+		// Entries that only exist to mark a registry as used by a credsStore carry no credentials
+		if auth.Username == "" && auth.Password == "" && auth.IdentityToken == "" && auth.RegistryToken == "" {
+			continue
+		}
+		s.Set(auth)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for server, helper := range cf.CredHelpers {
+		s.helpers[RegistryHost(server)] = helper
+	}
+	if cf.CredsStore != "" {
+		s.defaultHelper = cf.CredsStore
+	}
+
+	return nil
+}
+
+// RegistryHost normalizes a registry address such as https://index.docker.io/v1/ into its host name
+func RegistryHost(address string) string {
+	host := strings.ToLower(strings.TrimSpace(address))
+	host = strings.TrimPrefix(host, "https://")
+	host = strings.TrimPrefix(host, "http://")
+	if i := strings.Index(host, "/"); i >= 0 {
+		host = host[:i]
+	}
+
+	switch host {
+	case "", "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return dockerHubHost
+	}
+	return host
+}
+
+// ImageRegistryHost returns the registry host of an image reference, images without a domain resolve to Docker Hub
+func ImageRegistryHost(imageRef string) (string, error) {
+	named, err := reference.ParseNormalizedNamed(imageRef)
+	if err != nil {
+		return "", fmt.Errorf("invalid image reference %s: %w", imageRef, err)
+	}
+	return RegistryHost(reference.Domain(named)), nil
+}
+
+func defaultDockerConfigPath() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find home directory: %w", err)
+	}
+	return filepath.Join(home, ".docker", "config.json"), nil
+}
+
+func decodeBasicAuth(encoded string) (string, string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", err
+	}
+	user, password, ok := strings.Cut(string(decoded), ":")
+	if !ok {
+		return "", "", fmt.Errorf("auth is not in user:password format")
+	}
+	return user, password, nil
+}
+
+// getFromCredentialHelper runs docker-credential-<helper> get for the given host
+func getFromCredentialHelper(helper, host string) (registry.AuthConfig, bool, error) {
+	serverURL := host
+	if host == dockerHubHost {
+		serverURL = "https://index.docker.io/v1/"
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Helpers report unknown registries on stdout and exit with a non-zero code
+		if strings.Contains(stdout.String(), "credentials not found") {
+			return registry.AuthConfig{}, false, nil
+		}
+		return registry.AuthConfig{}, false, fmt.Errorf("credential helper %s failed for %s: %v: %s", helper, host, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var resp credentialHelperResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return registry.AuthConfig{}, false, fmt.Errorf("could not parse credential helper %s output: %w", helper, err)
+	}
+
+	auth := registry.AuthConfig{ServerAddress: host}
+	// Helpers return <token> as the username when the secret is an identity token
+	if resp.Username == "<token>" {
+		auth.IdentityToken = resp.Secret
+	} else {
+		auth.Username = resp.Username
+		auth.Password = resp.Secret
+	}
+
+	return auth, true, nil
+}
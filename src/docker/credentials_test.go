@@ -0,0 +1,117 @@
+package docker_test
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/common/config"
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+func TestImageRegistryHost(t *testing.T) {
+	tests := map[string]string{
+		"nginx:latest":       "docker.io",
+		"library/nginx":      "docker.io",
+		"ghcr.io/org/app:v1": "ghcr.io",
+		"localhost:5000/app": "localhost:5000",
+		"123456789012.dkr.ecr.eu-west-1.amazonaws.com/api:1.2": "123456789012.dkr.ecr.eu-west-1.amazonaws.com",
+	}
+
+	for ref, expected := range tests {
+		host, err := docker.ImageRegistryHost(ref)
+		require.NoError(t, err)
+		assert.Equal(t, expected, host, ref)
+	}
+}
+
+func TestCredentialStoreLoadDockerConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(configFile, []byte(`{
+		"auths": {
+			"https://index.docker.io/v1/": {"auth": "aHVidXNlcjpodWJwYXNz"},
+			"registry.example.com:5000": {"username": "reguser", "password": "regpass"},
+			"123456789012.dkr.ecr.eu-west-1.amazonaws.com": {}
+		},
+		"credHelpers": {"123456789012.dkr.ecr.eu-west-1.amazonaws.com": "ecr-login"}
+	}`), 0600)
+	require.NoError(t, err)
+
+	store := docker.NewCredentialStore()
+	require.NoError(t, store.LoadDockerConfig(configFile))
+
+	auth, ok, err := store.ForImage("nginx:latest")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "hubuser", auth.Username)
+	assert.Equal(t, "hubpass", auth.Password)
+
+	auth, ok, err = store.ForImage("registry.example.com:5000/team/app:v2")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "reguser", auth.Username)
+
+	_, ok, err = store.ForImage("ghcr.io/org/app")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestPullDockerImageUsesRegistryCredentials(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	store := docker.NewCredentialStore()
+	require.NoError(t, store.LoadConfig(config.Config{
+		Docker: config.Docker{
+			Registries: []config.Registry{
+				{Server: "ghcr.io", Username: "ghcruser", Password: "ghcrpass"},
+			},
+		},
+	}))
+	ghcrAuth, err := registry.EncodeAuthConfig(registry.AuthConfig{
+		Username:      "ghcruser",
+		Password:      "ghcrpass",
+		ServerAddress: "ghcr.io",
+	})
+	require.NoError(t, err)
+	// The last login was to ghcr.io, its credentials must not leak to Docker Hub
+	d := docker.Docker{
+		Client:      mockClient,
+		Credentials: store,
+		EncodedAuth: ghcrAuth,
+	}
+
+	mockClient.On("ImagePull", mock.Anything, "ghcr.io/org/app:v1", mock.MatchedBy(func(opts image.PullOptions) bool {
+		return opts.RegistryAuth == ghcrAuth
+	})).Return(io.NopCloser(bytes.NewReader(nil)), nil)
+	mockClient.On("ImagePush", mock.Anything, "nginx:latest", mock.MatchedBy(func(opts image.PushOptions) bool {
+		return opts.RegistryAuth == ""
+	})).Return(io.NopCloser(bytes.NewReader(nil)), nil)
+
+	require.NoError(t, d.PullDockerImage("ghcr.io/org/app:v1"))
+	require.NoError(t, d.PushDockerImage("nginx:latest"))
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestLoadConfigRejectsSecretReferences(t *testing.T) {
+	store := docker.NewCredentialStore()
+	err := store.LoadConfig(config.Config{
+		Docker: config.Docker{
+			Registries: []config.Registry{
+				{Server: "ghcr.io", Username: "ghcruser", Password: "ssm:/deploy/ghcr/password"},
+			},
+		},
+	})
+	assert.ErrorContains(t, err, "unresolved secret references in docker.registries[0].password")
+
+	_, ok, err := store.Get("ghcr.io")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
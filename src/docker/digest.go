@@ -0,0 +1,195 @@
+package docker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/distribution/reference"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxDeployHistory is how many deploys DeployState keeps per service, older ones are dropped
+const maxDeployHistory = 20
+
+// PinnedImage is a mutable image reference resolved to the digest it pointed to in the registry
+type PinnedImage struct {
+	// Ref is the reference that was resolved, such as nginx:latest
+	Ref    string `json:"ref"`
+	Digest string `json:"digest"`
+	// Pinned is the immutable reference to deploy, such as docker.io/library/nginx@sha256:...
+	Pinned string `json:"pinned"`
+}
+
+// ResolveImageDigest resolves a tag to the digest it currently points to through the registry distribution API
+// The credentials stored for the image registry are used, references that already contain a digest are returned as is
+func (d *Docker) ResolveImageDigest(imageRef string) (PinnedImage, error) {
+	named, err := reference.ParseNormalizedNamed(imageRef)
+	if err != nil {
+		return PinnedImage{}, fmt.Errorf("invalid image reference %s: %w", imageRef, err)
+	}
+
+	if canonical, ok := named.(reference.Canonical); ok {
+		return PinnedImage{
+			Ref:    imageRef,
+			Digest: canonical.Digest().String(),
+			Pinned: reference.TrimNamed(canonical).String() + "@" + canonical.Digest().String(),
+		}, nil
+	}
+
+	inspect, err := d.distributionInspect(imageRef)
+	if err != nil {
+		return PinnedImage{}, err
+	}
+	digested, err := reference.WithDigest(reference.TrimNamed(named), inspect.Descriptor.Digest)
+	if err != nil {
+		return PinnedImage{}, fmt.Errorf("registry returned an invalid digest for %s: %w", imageRef, err)
+	}
+
+	return PinnedImage{
+		Ref:    imageRef,
+		Digest: inspect.Descriptor.Digest.String(),
+		Pinned: digested.String(),
+	}, nil
+}
+
+// PinComposeImages resolves the image of every service and replaces it with the pinned reference
+// Running ComposeUp with the same pinned project on every host deploys exactly the same images
+// The project is only changed when every image could be resolved
+func (d *Docker) PinComposeImages(project *ComposeProject) (map[string]PinnedImage, error) {
+	pinned := map[string]PinnedImage{}
+	for name, service := range project.Services {
+		image, err := d.ResolveImageDigest(service.Image)
+		if err != nil {
+			return nil, fmt.Errorf("could not pin image of service %s: %w", name, err)
+		}
+		pinned[name] = image
+	}
+
+	for name, image := range pinned {
+		service := project.Services[name]
+		service.Image = image.Pinned
+		project.Services[name] = service
+	}
+	return pinned, nil
+}
+
+// DeployRecord is a single deploy of a service
+type DeployRecord struct {
+	Image      PinnedImage `json:"image"`
+	DeployedAt time.Time   `json:"deployed_at"`
+}
+
+// DeployState records the pinned images deployed per service so a rollback can redeploy the exact previous image
+// It is stored as JSON, the latest deploy of a service is the last entry of its history
+type DeployState struct {
+	mu       sync.Mutex
+	Services map[string][]DeployRecord `json:"services"`
+}
+
+// LoadDeployState reads a deploy state file, a missing file returns an empty state
+func LoadDeployState(path string) (*DeployState, error) {
+	state := &DeployState{Services: map[string][]DeployRecord{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read deploy state %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("could not parse deploy state %s: %w", path, err)
+	}
+	if state.Services == nil {
+		state.Services = map[string][]DeployRecord{}
+	}
+
+	return state, nil
+}
+
+// Save writes the state to path, the file is replaced atomically so a failed write never loses the previous state
+func (s *DeployState) Save(path string) error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("could not encode deploy state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("could not write deploy state %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write deploy state %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write deploy state %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not write deploy state %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// Record adds a deploy of service, deploying the image the service already runs doesn't add a new entry
+func (s *DeployState) Record(service string, image PinnedImage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Services == nil {
+		s.Services = map[string][]DeployRecord{}
+	}
+	history := s.Services[service]
+	if len(history) > 0 && history[len(history)-1].Image.Digest == image.Digest {
+		return
+	}
+
+	history = append(history, DeployRecord{Image: image, DeployedAt: time.Now().UTC()})
+	if len(history) > maxDeployHistory {
+		history = history[len(history)-maxDeployHistory:]
+	}
+	s.Services[service] = history
+}
+
+// Current returns the latest deploy of service
+func (s *DeployState) Current(service string) (DeployRecord, bool) {
+	return s.fromLatest(service, 0)
+}
+
+// Previous returns the deploy before the latest one, it is the image a rollback should deploy
+func (s *DeployState) Previous(service string) (DeployRecord, bool) {
+	return s.fromLatest(service, 1)
+}
+
+// ServiceNames returns the services with at least one recorded deploy
+func (s *DeployState) ServiceNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.Services))
+	for name := range s.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *DeployState) fromLatest(service string, offset int) (DeployRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := s.Services[service]
+	if len(history) <= offset {
+		return DeployRecord{}, false
+	}
+	return history[len(history)-1-offset], true
+}
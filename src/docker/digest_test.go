@@ -0,0 +1,100 @@
+package docker_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+func TestResolveImageDigest(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	credentials := docker.NewCredentialStore()
+	credentials.Set(registry.AuthConfig{ServerAddress: "registry.example.com", Username: "deploy", Password: "secret"})
+	d := docker.Docker{Client: mockClient, Ctx: context.Background(), Credentials: credentials}
+
+	mockClient.On("DistributionInspect", mock.Anything, "registry.example.com/shop/api:latest", mock.MatchedBy(func(auth string) bool {
+		return auth != ""
+	})).Return(registry.DistributionInspect{Descriptor: ocispec.Descriptor{Digest: newDigest}}, nil)
+
+	pinned, err := d.ResolveImageDigest("registry.example.com/shop/api:latest")
+	require.NoError(t, err)
+	assert.Equal(t, newDigest, pinned.Digest)
+	assert.Equal(t, "registry.example.com/shop/api@"+newDigest, pinned.Pinned)
+}
+
+func TestResolveImageDigestAlreadyPinned(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	pinned, err := d.ResolveImageDigest("nginx:1.27@" + nginxDigest)
+	require.NoError(t, err)
+	assert.Equal(t, "docker.io/library/nginx@"+nginxDigest, pinned.Pinned)
+	mockClient.AssertNotCalled(t, "DistributionInspect", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestPinComposeImages(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+	project := &docker.ComposeProject{Name: "shop", Services: map[string]docker.ComposeService{
+		"web": {Image: "nginx:1.27"},
+	}}
+
+	mockClient.On("DistributionInspect", mock.Anything, "nginx:1.27", "").Return(registry.DistributionInspect{
+		Descriptor: ocispec.Descriptor{Digest: nginxDigest},
+	}, nil)
+
+	pinned, err := d.PinComposeImages(project)
+	require.NoError(t, err)
+	assert.Equal(t, nginxDigest, pinned["web"].Digest)
+	assert.Equal(t, "docker.io/library/nginx@"+nginxDigest, project.Services["web"].Image)
+}
+
+func TestPinComposeImagesRegistryError(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+	project := &docker.ComposeProject{Name: "shop", Services: map[string]docker.ComposeService{
+		"web": {Image: "nginx:1.27"},
+	}}
+
+	mockClient.On("DistributionInspect", mock.Anything, "nginx:1.27", "").Return(registry.DistributionInspect{}, errors.New("unauthorized"))
+
+	_, err := d.PinComposeImages(project)
+	assert.ErrorContains(t, err, "could not pin image of service web")
+	assert.Equal(t, "nginx:1.27", project.Services["web"].Image)
+}
+
+func TestDeployState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy-state.json")
+
+	state, err := docker.LoadDeployState(path)
+	require.NoError(t, err)
+	_, ok := state.Current("web")
+	assert.False(t, ok)
+
+	v1 := docker.PinnedImage{Ref: "nginx:latest", Digest: nginxDigest, Pinned: "docker.io/library/nginx@" + nginxDigest}
+	v2 := docker.PinnedImage{Ref: "nginx:latest", Digest: newDigest, Pinned: "docker.io/library/nginx@" + newDigest}
+	state.Record("web", v1)
+	state.Record("web", v2)
+	state.Record("web", v2)
+	require.NoError(t, state.Save(path))
+
+	loaded, err := docker.LoadDeployState(path)
+	require.NoError(t, err)
+	current, ok := loaded.Current("web")
+	require.True(t, ok)
+	assert.Equal(t, v2, current.Image)
+	previous, ok := loaded.Previous("web")
+	require.True(t, ok)
+	assert.Equal(t, v1, previous.Image)
+	assert.Len(t, loaded.Services["web"], 2)
+	assert.Equal(t, []string{"web"}, loaded.ServiceNames())
+}
@@ -10,84 +10,178 @@ import (
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/registry"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 )
 
-// Docker struct is used to pass Context and EncodedAuth around client.APIClient interface is used to make mock testing easier
+// Docker struct is used to pass Context and credentials around client.APIClient interface is used to make mock testing easier
+// Credentials is used to pick registry auth per image, images of registries without credentials are pulled anonymously
+// Set Credentials or call LoginDocker before sharing a Docker object between goroutines, a nil store is never filled in behind the caller
 type Docker struct {
-	Ctx         context.Context
-	Client      client.APIClient
+	Ctx    context.Context
+	Client client.APIClient
+	// Deprecated: EncodedAuth is only set by LoginDocker and never read, registry auth is picked from Credentials per image
 	EncodedAuth string
+	Credentials *CredentialStore
 }
 
-// ClientOption defines the type for functional options.
-type ClientOption func(*client.Client) error
+// ClientOption defines the type for functional options, options only record settings and NewClient builds a single client from all of them
+type ClientOption func(*clientConfig) error
 
-// WithHost sets the host for the Docker client.
+// clientConfig accumulates the settings of ClientOptions
+type clientConfig struct {
+	host        string
+	apiVersion  string
+	timeout     time.Duration
+	tlsConfig   *tls.Config
+	httpClient  *http.Client
+	dialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// WithHost sets the host for the Docker client, e.g. tcp://10.0.0.5:2376, unix:///var/run/docker.sock or ssh://user@10.0.0.5
 func WithHost(host string) ClientOption {
-	return func(c *client.Client) error {
-		newClient, err := client.NewClientWithOpts(client.WithHost(host))
-		if err != nil {
-			return err
-		}
-		*c = *newClient
+	return func(c *clientConfig) error {
+		c.host = host
 		return nil
 	}
 }
 
 // WithTLS sets the TLS configuration for the Docker client.
 func WithTLS(certFile, keyFile, caFile string) ClientOption {
-	return func(c *client.Client) error {
-		// Create the TLS configuration.
+	return func(c *clientConfig) error {
 		tlsConfig, err := NewTLSConfig(certFile, keyFile, caFile)
 		if err != nil {
 			return err
 		}
+		c.tlsConfig = tlsConfig
+		return nil
+	}
+}
 
-		// Create a custom http.Client with the TLS configuration.
-		customTransport := &http.Transport{
-			TLSClientConfig: tlsConfig,
-		}
-		customHTTPClient := &http.Client{
-			Transport: customTransport,
-		}
+// WithAPIVersion pins the Docker API version, by default the version is negotiated with the daemon
+func WithAPIVersion(version string) ClientOption {
+	return func(c *clientConfig) error {
+		c.apiVersion = version
+		return nil
+	}
+}
 
-		// Create a new Docker client with the custom http.Client.
-		newClient, err := client.NewClientWithOpts(
-			client.FromEnv,
-			client.WithAPIVersionNegotiation(),
-			client.WithHTTPClient(customHTTPClient),
-		)
-		if err != nil {
-			return err
-		}
-		*c = *newClient
+// WithTimeout sets the timeout of every request, it also limits streaming calls such as following logs or events
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *clientConfig) error {
+		c.timeout = timeout
 		return nil
 	}
 }
 
-// NewClient creates a new Docker client with the given options.
-func NewClient(options ...ClientOption) (*client.Client, error) {
-	cli, err := client.NewClientWithOpts(
-		client.FromEnv,
-		client.WithAPIVersionNegotiation(),
-	)
-	if err != nil {
-		return nil, fmt.Errorf("could not create docker client handle: %w", err)
+// WithHTTPClient sets a custom http.Client, TLS and dialer options are applied to a copy of its transport
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *clientConfig) error {
+		c.httpClient = httpClient
+		return nil
 	}
+}
 
-	// Apply the options
+// WithDialContext sets the function used to open connections to the daemon, it is used to tunnel the Docker API through other transports such as SSH
+func WithDialContext(dialContext func(ctx context.Context, network, addr string) (net.Conn, error)) ClientOption {
+	return func(c *clientConfig) error {
+		c.dialContext = dialContext
+		return nil
+	}
+}
+
+// NewClient creates a new Docker client with the given options.
+// Settings not given as options are read from the DOCKER_HOST, DOCKER_TLS_VERIFY, DOCKER_CERT_PATH and DOCKER_API_VERSION environment variables
+func NewClient(options ...ClientOption) (*client.Client, error) {
+	var cfg clientConfig
 	for _, option := range options {
-		if err := option(cli); err != nil {
+		if err := option(&cfg); err != nil {
 			return nil, fmt.Errorf("could not apply option: %w", err)
 		}
 	}
 
+	opts := []client.Opt{client.FromEnv}
+
+	// The docker client sets the timeout, dialer and TLS config on the http client it is given, so it always gets a copy
+	httpClient := cfg.httpClient
+	if httpClient != nil {
+		copied := *httpClient
+		httpClient = &copied
+		switch transport := httpClient.Transport.(type) {
+		case nil:
+			httpClient.Transport = cloneTransport(http.DefaultTransport.(*http.Transport))
+		case *http.Transport:
+			httpClient.Transport = cloneTransport(transport)
+		}
+	}
+	if cfg.tlsConfig != nil {
+		if httpClient == nil {
+			httpClient = &http.Client{Transport: &http.Transport{}}
+		}
+		transport, ok := httpClient.Transport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("could not apply TLS config to transport %T", httpClient.Transport)
+		}
+		transport.TLSClientConfig = cfg.tlsConfig
+	}
+	if httpClient != nil {
+		opts = append(opts, client.WithHTTPClient(httpClient))
+	}
+
+	// The host configures the transport for its protocol so it has to be applied after the http client is set
+	host := cfg.host
+	if host == "" {
+		host = os.Getenv(client.EnvOverrideHost)
+	}
+	if host == "" {
+		host = client.DefaultDockerHost
+	}
+	if strings.HasPrefix(host, "ssh://") && cfg.dialContext == nil {
+		sshCtx, port, err := parseSSHHost(host)
+		if err != nil {
+			return nil, err
+		}
+		host = sshDaemonHost
+		cfg.dialContext = sshDialer(sshCtx, port)
+	}
+	opts = append(opts, client.WithHost(host))
+
+	// A custom dialer replaces the dialer WithHost sets up
+	if cfg.dialContext != nil {
+		opts = append(opts, client.WithDialContext(cfg.dialContext))
+	}
+	if cfg.apiVersion != "" {
+		opts = append(opts, client.WithVersion(cfg.apiVersion))
+	} else {
+		opts = append(opts, client.WithAPIVersionNegotiation())
+	}
+	if cfg.timeout > 0 {
+		opts = append(opts, client.WithTimeout(cfg.timeout))
+	}
+
+	cli, err := client.NewClientWithOpts(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create docker client handle: %w", err)
+	}
+
 	return cli, nil
 }
 
+// cloneTransport clones transport, Clone sets up a TLS config for HTTP/2 that would make the docker client use https so it is dropped again
+func cloneTransport(transport *http.Transport) *http.Transport {
+	plain := transport.TLSClientConfig == nil
+	clone := transport.Clone()
+	if plain {
+		clone.TLSClientConfig = nil
+	}
+	return clone
+}
+
 // NewTLS creates a TLS configuration for the Docker client.
 func NewTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
 	// Load client cert
@@ -115,14 +209,15 @@ func NewTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
 	return tlsConfig, nil
 }
 
-// LoginDocker logs in registry with given credentials and sets encodedauth to Docker object for further use in other functions
+// LoginDocker logs in registry with given credentials and stores them in Credentials for further use in other functions
+// Credentials is created when it is nil, so the first login must not run concurrently with other calls
 func (d *Docker) LoginDocker(user, password, registryUri string) error {
 	auth := registry.AuthConfig{
 		Username:      user,
 		Password:      password,
 		ServerAddress: registryUri,
 	}
-	_, err := d.Client.RegistryLogin(nil, auth)
+	_, err := d.Client.RegistryLogin(d.Ctx, auth)
 	if err != nil {
 		return err
 	}
@@ -131,14 +226,23 @@ func (d *Docker) LoginDocker(user, password, registryUri string) error {
 		return err
 	}
 	d.EncodedAuth = ea
+	if d.Credentials == nil {
+		d.Credentials = NewCredentialStore()
+	}
+	d.Credentials.Set(auth)
 	return nil
 }
 
-// PullDockerImage pulls a Docker image from a registry
+// PullDockerImage pulls a Docker image from a registry using the credentials stored for the image registry
 func (d *Docker) PullDockerImage(imageRef string) error {
+	auth, err := d.registryAuth(imageRef)
+	if err != nil {
+		return err
+	}
+
 	out, err := d.Client.ImagePull(d.Ctx, imageRef, image.PullOptions{
 		All:           false,
-		RegistryAuth:  "",
+		RegistryAuth:  auth,
 		PrivilegeFunc: nil,
 		Platform:      "",
 	})
@@ -155,9 +259,66 @@ func (d *Docker) PullDockerImage(imageRef string) error {
 	return nil
 }
 
-// PruneAll prunes all unused and dangling docker objects
-func (d *Docker) PruneAll() (uint64, error) {
-	var spaceReclaimed uint64
+// ensureImage pulls imageRef unless it is already present on the host
+func (d *Docker) ensureImage(imageRef string) error {
+	_, _, err := d.Client.ImageInspectWithRaw(d.Ctx, imageRef)
+	if err == nil {
+		return nil
+	}
+	if !errdefs.IsNotFound(err) {
+		return fmt.Errorf("could not inspect image %s: %w", imageRef, err)
+	}
+	return d.PullDockerImage(imageRef)
+}
+
+// PushDockerImage pushes a Docker image to a registry using the credentials stored for the image registry
+func (d *Docker) PushDockerImage(imageRef string) error {
+	auth, err := d.registryAuth(imageRef)
+	if err != nil {
+		return err
+	}
+
+	out, err := d.Client.ImagePush(d.Ctx, imageRef, image.PushOptions{
+		RegistryAuth: auth,
+	})
+	if err != nil {
+		return fmt.Errorf("error pushing Docker image: %w", err)
+	}
+	defer out.Close()
+
+	_, err = io.Copy(os.Stdout, out)
+	if err != nil {
+		return fmt.Errorf("error reading output: %w", err)
+	}
+
+	return nil
+}
+
+// baseContext returns Ctx, or context.Background() when Docker was created without one
+func (d *Docker) baseContext() context.Context {
+	if d.Ctx == nil {
+		return context.Background()
+	}
+	return d.Ctx
+}
+
+// registryAuth returns encoded credentials for the image registry, registries without credentials get an empty auth
+// EncodedAuth is never used as a fallback since it would send the credentials of one registry to another
+func (d *Docker) registryAuth(imageRef string) (string, error) {
+	if d.Credentials == nil {
+		return "", nil
+	}
+	auth, err := d.Credentials.EncodedAuthForImage(imageRef)
+	if err != nil {
+		return "", fmt.Errorf("could not get registry credentials for %s: %w", imageRef, err)
+	}
+	return auth, nil
+}
+
+// PruneAll prunes all unused and dangling docker objects including volumes, use Prune with a PrunePolicy to choose what gets removed
+// On failure the report holds the results of the object types pruned before the error
+func (d *Docker) PruneAll() (PruneReport, error) {
+	var report PruneReport
 	pruneFilters := filters.NewArgs()
 	pruneFilters.Add("dangling", "false")
 
@@ -167,32 +328,39 @@ func (d *Docker) PruneAll() (uint64, error) {
 		Filters:     pruneFilters,
 	})
 	if err != nil {
-		return spaceReclaimed, err
+		return report, fmt.Errorf("could not prune build cache: %w", err)
 	}
-	spaceReclaimed += bc.SpaceReclaimed
+	report.BuildCache = PruneResult{Deleted: bc.CachesDeleted, SpaceReclaimed: bc.SpaceReclaimed}
 
 	im, err := d.Client.ImagesPrune(d.Ctx, pruneFilters)
 	if err != nil {
-		return spaceReclaimed, err
+		return report, fmt.Errorf("could not prune images: %w", err)
+	}
+	report.Images.SpaceReclaimed = im.SpaceReclaimed
+	for _, deleted := range im.ImagesDeleted {
+		// Untagged entries only remove a tag, the image itself is listed separately once it is deleted
+		if deleted.Deleted != "" {
+			report.Images.Deleted = append(report.Images.Deleted, deleted.Deleted)
+		}
 	}
-	spaceReclaimed += im.SpaceReclaimed
 
 	con, err := d.Client.ContainersPrune(d.Ctx, pruneFilters)
 	if err != nil {
-		return spaceReclaimed, err
+		return report, fmt.Errorf("could not prune containers: %w", err)
 	}
-	spaceReclaimed += con.SpaceReclaimed
+	report.Containers = PruneResult{Deleted: con.ContainersDeleted, SpaceReclaimed: con.SpaceReclaimed}
 
-	_, err = d.Client.NetworksPrune(d.Ctx, pruneFilters)
+	nw, err := d.Client.NetworksPrune(d.Ctx, pruneFilters)
 	if err != nil {
-		return spaceReclaimed, err
+		return report, fmt.Errorf("could not prune networks: %w", err)
 	}
+	report.Networks = PruneResult{Deleted: nw.NetworksDeleted}
 
 	vol, err := d.Client.VolumesPrune(d.Ctx, pruneFilters)
 	if err != nil {
-		return spaceReclaimed, err
+		return report, fmt.Errorf("could not prune volumes: %w", err)
 	}
-	spaceReclaimed += vol.SpaceReclaimed
+	report.Volumes = PruneResult{Deleted: vol.VolumesDeleted, SpaceReclaimed: vol.SpaceReclaimed}
 
-	return spaceReclaimed, nil
+	return report, nil
 }
@@ -3,11 +3,14 @@ package docker_test
 import (
 	"bytes"
 	"context"
+	"errors"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 	"io"
 	"testing"
 
@@ -37,6 +40,11 @@ func (m *MockDockerClient) ImagePull(ctx context.Context, ref string, options im
 	return args.Get(0).(io.ReadCloser), args.Error(1)
 }
 
+func (m *MockDockerClient) ImagePush(ctx context.Context, ref string, options image.PushOptions) (io.ReadCloser, error) {
+	args := m.Called(ctx, ref, options)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
 func (m *MockDockerClient) BuildCachePrune(ctx context.Context, opts types.BuildCachePruneOptions) (*types.BuildCachePruneReport, error) {
 	args := m.Called(ctx, opts)
 	return args.Get(0).(*types.BuildCachePruneReport), args.Error(1)
@@ -62,6 +70,156 @@ func (m *MockDockerClient) VolumesPrune(ctx context.Context, filters filters.Arg
 	return args.Get(0).(volume.PruneReport), args.Error(1)
 }
 
+func (m *MockDockerClient) ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).([]types.Container), args.Error(1)
+}
+
+func (m *MockDockerClient) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	args := m.Called(ctx, config, hostConfig, networkingConfig, platform, containerName)
+	return args.Get(0).(container.CreateResponse), args.Error(1)
+}
+
+func (m *MockDockerClient) DaemonHost() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *MockDockerClient) ContainerWait(ctx context.Context, containerID string, condition container.WaitCondition) (<-chan container.WaitResponse, <-chan error) {
+	args := m.Called(ctx, containerID, condition)
+	return args.Get(0).(chan container.WaitResponse), args.Get(1).(chan error)
+}
+
+func (m *MockDockerClient) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, types.ContainerPathStat, error) {
+	args := m.Called(ctx, containerID, srcPath)
+	return args.Get(0).(io.ReadCloser), args.Get(1).(types.ContainerPathStat), args.Error(2)
+}
+
+func (m *MockDockerClient) CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader, options container.CopyToContainerOptions) error {
+	args := m.Called(ctx, containerID, dstPath, content, options)
+	return args.Error(0)
+}
+
+func (m *MockDockerClient) ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error {
+	args := m.Called(ctx, containerID, options)
+	return args.Error(0)
+}
+
+func (m *MockDockerClient) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	args := m.Called(ctx, containerID, options)
+	return args.Error(0)
+}
+
+func (m *MockDockerClient) ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error {
+	args := m.Called(ctx, containerID, options)
+	return args.Error(0)
+}
+
+func (m *MockDockerClient) ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).([]image.Summary), args.Error(1)
+}
+
+func (m *MockDockerClient) DistributionInspect(ctx context.Context, imageRef, encodedRegistryAuth string) (registry.DistributionInspect, error) {
+	args := m.Called(ctx, imageRef, encodedRegistryAuth)
+	return args.Get(0).(registry.DistributionInspect), args.Error(1)
+}
+
+func (m *MockDockerClient) ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error) {
+	args := m.Called(ctx, imageID)
+	return args.Get(0).(types.ImageInspect), nil, args.Error(1)
+}
+
+func (m *MockDockerClient) NetworkInspect(ctx context.Context, networkID string, options network.InspectOptions) (network.Inspect, error) {
+	args := m.Called(ctx, networkID, options)
+	return args.Get(0).(network.Inspect), args.Error(1)
+}
+
+func (m *MockDockerClient) NetworkCreate(ctx context.Context, name string, options network.CreateOptions) (network.CreateResponse, error) {
+	args := m.Called(ctx, name, options)
+	return args.Get(0).(network.CreateResponse), args.Error(1)
+}
+
+func (m *MockDockerClient) NetworkConnect(ctx context.Context, networkID, containerID string, config *network.EndpointSettings) error {
+	args := m.Called(ctx, networkID, containerID, config)
+	return args.Error(0)
+}
+
+func (m *MockDockerClient) NetworkDisconnect(ctx context.Context, networkID, containerID string, force bool) error {
+	args := m.Called(ctx, networkID, containerID, force)
+	return args.Error(0)
+}
+
+func (m *MockDockerClient) NetworkRemove(ctx context.Context, networkID string) error {
+	args := m.Called(ctx, networkID)
+	return args.Error(0)
+}
+
+func (m *MockDockerClient) VolumeInspect(ctx context.Context, volumeID string) (volume.Volume, error) {
+	args := m.Called(ctx, volumeID)
+	return args.Get(0).(volume.Volume), args.Error(1)
+}
+
+func (m *MockDockerClient) VolumeList(ctx context.Context, options volume.ListOptions) (volume.ListResponse, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(volume.ListResponse), args.Error(1)
+}
+
+func (m *MockDockerClient) VolumeCreate(ctx context.Context, options volume.CreateOptions) (volume.Volume, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(volume.Volume), args.Error(1)
+}
+
+func (m *MockDockerClient) VolumeRemove(ctx context.Context, volumeID string, force bool) error {
+	args := m.Called(ctx, volumeID, force)
+	return args.Error(0)
+}
+
+func (m *MockDockerClient) DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(types.DiskUsage), args.Error(1)
+}
+
+func (m *MockDockerClient) NetworkList(ctx context.Context, options network.ListOptions) ([]network.Summary, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).([]network.Summary), args.Error(1)
+}
+
+func (m *MockDockerClient) ImageRemove(ctx context.Context, imageID string, options image.RemoveOptions) ([]image.DeleteResponse, error) {
+	args := m.Called(ctx, imageID, options)
+	return args.Get(0).([]image.DeleteResponse), args.Error(1)
+}
+
+func (m *MockDockerClient) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	args := m.Called(ctx, containerID)
+	return args.Get(0).(types.ContainerJSON), args.Error(1)
+}
+
+func (m *MockDockerClient) ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
+	args := m.Called(ctx, containerID, options)
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockDockerClient) Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).(chan events.Message), args.Get(1).(chan error)
+}
+
+func (m *MockDockerClient) ContainerExecCreate(ctx context.Context, containerID string, options container.ExecOptions) (types.IDResponse, error) {
+	args := m.Called(ctx, containerID, options)
+	return args.Get(0).(types.IDResponse), args.Error(1)
+}
+
+func (m *MockDockerClient) ContainerExecAttach(ctx context.Context, execID string, options container.ExecAttachOptions) (types.HijackedResponse, error) {
+	args := m.Called(ctx, execID, options)
+	return args.Get(0).(types.HijackedResponse), args.Error(1)
+}
+
+func (m *MockDockerClient) ContainerExecInspect(ctx context.Context, execID string) (container.ExecInspect, error) {
+	args := m.Called(ctx, execID)
+	return args.Get(0).(container.ExecInspect), args.Error(1)
+}
+
 func TestLoginDocker(t *testing.T) {
 	mockClient := new(MockDockerClient)
 	d := docker.Docker{
@@ -100,10 +258,14 @@ func TestPullDockerImage(t *testing.T) {
 	// Create a buffer with some dummy data to simulate Docker pull output
 	dummyData := []byte("dummy image pull data")
 	mockReadCloser := io.NopCloser(bytes.NewReader(dummyData))
-	mockClient.On("ImagePull", mock.Anything, imageRef, mock.Anything).Return(mockReadCloser, nil)
+	mockClient.On("ImagePull", mock.Anything, imageRef, mock.MatchedBy(func(opts image.PullOptions) bool {
+		return opts.RegistryAuth == ""
+	})).Return(mockReadCloser, nil)
 
 	err := d.PullDockerImage(imageRef)
 	require.NoError(t, err)
+	// Reads never create the credential store, concurrent pulls would race on it
+	assert.Nil(t, d.Credentials)
 
 	mockClient.AssertExpectations(t)
 }
@@ -119,15 +281,43 @@ func TestPruneAll(t *testing.T) {
 	pruneFilters := filters.NewArgs()
 	pruneFilters.Add("dangling", "false")
 
-	mockClient.On("BuildCachePrune", ctx, mock.Anything).Return(&types.BuildCachePruneReport{SpaceReclaimed: 100}, nil)
-	mockClient.On("ImagesPrune", ctx, pruneFilters).Return(image.PruneReport{SpaceReclaimed: 200}, nil)
-	mockClient.On("ContainersPrune", ctx, pruneFilters).Return(container.PruneReport{SpaceReclaimed: 300}, nil)
-	mockClient.On("NetworksPrune", ctx, pruneFilters).Return(network.PruneReport{}, nil)
-	mockClient.On("VolumesPrune", ctx, pruneFilters).Return(volume.PruneReport{SpaceReclaimed: 400}, nil)
+	mockClient.On("BuildCachePrune", ctx, mock.Anything).Return(&types.BuildCachePruneReport{CachesDeleted: []string{"cache1"}, SpaceReclaimed: 100}, nil)
+	mockClient.On("ImagesPrune", ctx, pruneFilters).Return(image.PruneReport{
+		ImagesDeleted:  []image.DeleteResponse{{Untagged: "app:v1"}, {Deleted: "sha256:app1"}},
+		SpaceReclaimed: 200,
+	}, nil)
+	mockClient.On("ContainersPrune", ctx, pruneFilters).Return(container.PruneReport{ContainersDeleted: []string{"c1", "c2"}, SpaceReclaimed: 300}, nil)
+	mockClient.On("NetworksPrune", ctx, pruneFilters).Return(network.PruneReport{NetworksDeleted: []string{"net1"}}, nil)
+	mockClient.On("VolumesPrune", ctx, pruneFilters).Return(volume.PruneReport{VolumesDeleted: []string{"vol1"}, SpaceReclaimed: 400}, nil)
 
-	spaceReclaimed, err := d.PruneAll()
+	report, err := d.PruneAll()
 	require.NoError(t, err)
-	assert.Equal(t, uint64(1000), spaceReclaimed)
+	assert.Equal(t, uint64(1000), report.SpaceReclaimed())
+	assert.Equal(t, []string{"cache1"}, report.BuildCache.Deleted)
+	assert.Equal(t, []string{"sha256:app1"}, report.Images.Deleted)
+	assert.Equal(t, []string{"c1", "c2"}, report.Containers.Deleted)
+	assert.Equal(t, []string{"net1"}, report.Networks.Deleted)
+	assert.Equal(t, []string{"vol1"}, report.Volumes.Deleted)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestPruneAllReturnsPartialReport(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	ctx := context.Background()
+	d := docker.Docker{
+		Client: mockClient,
+		Ctx:    ctx,
+	}
+
+	mockClient.On("BuildCachePrune", ctx, mock.Anything).Return(&types.BuildCachePruneReport{SpaceReclaimed: 100}, nil)
+	mockClient.On("ImagesPrune", ctx, mock.Anything).Return(image.PruneReport{SpaceReclaimed: 200}, nil)
+	mockClient.On("ContainersPrune", ctx, mock.Anything).Return(container.PruneReport{}, errors.New("daemon unavailable"))
+
+	report, err := d.PruneAll()
+	require.ErrorContains(t, err, "could not prune containers")
+	assert.Equal(t, uint64(300), report.SpaceReclaimed())
 
+	mockClient.AssertNotCalled(t, "VolumesPrune", mock.Anything, mock.Anything)
 	mockClient.AssertExpectations(t)
 }
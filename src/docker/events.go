@@ -0,0 +1,183 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultReconnectDelay is how long WatchEvents waits before subscribing again after the stream broke
+const defaultReconnectDelay = 2 * time.Second
+
+// ContainerEvent is a container lifecycle event such as die, oom or restart
+type ContainerEvent struct {
+	ID     string
+	Name   string
+	Image  string
+	Action events.Action
+	// ExitCode is only set for die events
+	ExitCode int
+	Time     time.Time
+	// Attributes holds the actor attributes other than name, image and exitCode
+	// They are the container labels mixed with attributes of the action such as signal or execDuration
+	Attributes map[string]string
+}
+
+// HealthEvent is a health_status event of a container
+type HealthEvent struct {
+	ContainerEvent
+	// Status is healthy, unhealthy or starting
+	Status string
+}
+
+// ImageEvent is an image event such as pull, push or delete, Ref is the image reference or ID
+type ImageEvent struct {
+	Ref    string
+	Action events.Action
+	Time   time.Time
+}
+
+// EventHandlers holds the callbacks WatchEvents calls, nil handlers are skipped
+// Handlers are called one at a time in the order docker reported the events, a slow handler delays the others
+type EventHandlers struct {
+	OnContainerStart   func(ContainerEvent)
+	OnContainerDie     func(ContainerEvent)
+	OnContainerOOM     func(ContainerEvent)
+	OnContainerRestart func(ContainerEvent)
+	OnHealthStatus     func(HealthEvent)
+	OnImagePull        func(ImageEvent)
+	OnImageDelete      func(ImageEvent)
+	// OnEvent is called for every event, including the ones above
+	OnEvent func(events.Message)
+	// OnReconnect is called with the error that broke the stream before subscribing again
+	OnReconnect func(error)
+}
+
+// EventWatchOptions configures WatchEvents
+type EventWatchOptions struct {
+	// Filters limits the events docker sends, for example type=container or label=com.example.app
+	Filters filters.Args
+	// ReconnectDelay is the wait between reconnect attempts, defaults to 2 seconds
+	ReconnectDelay time.Duration
+}
+
+// WatchEvents subscribes to the docker events stream and calls the matching handlers until ctx is cancelled
+// A broken stream is subscribed again from the last received event, or from the first subscription when no event arrived yet, so no events are lost while the daemon is reachable
+func (d *Docker) WatchEvents(ctx context.Context, opts EventWatchOptions, handlers EventHandlers) error {
+	delay := opts.ReconnectDelay
+	if delay <= 0 {
+		delay = defaultReconnectDelay
+	}
+
+	since := eventsSince(time.Now().UnixNano())
+	for {
+		err := d.watchEvents(ctx, opts.Filters, since, handlers, func(msg events.Message) {
+			// Resume right after the last event, Since is inclusive
+			since = eventsSince(msg.TimeNano + 1)
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if handlers.OnReconnect != nil {
+			handlers.OnReconnect(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// watchEvents reads one events subscription until it breaks and returns the error that ended it
+func (d *Docker) watchEvents(ctx context.Context, args filters.Args, since string, handlers EventHandlers, seen func(events.Message)) error {
+	subCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	messages, errs := d.Client.Events(subCtx, events.ListOptions{Since: since, Filters: args})
+	for {
+		select {
+		case msg := <-messages:
+			seen(msg)
+			dispatchEvent(msg, handlers)
+		case err := <-errs:
+			if err == nil {
+				err = fmt.Errorf("events stream closed")
+			}
+			return fmt.Errorf("could not read docker events: %w", err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// dispatchEvent calls the typed handler matching msg
+func dispatchEvent(msg events.Message, handlers EventHandlers) {
+	if handlers.OnEvent != nil {
+		handlers.OnEvent(msg)
+	}
+
+	switch msg.Type {
+	case events.ContainerEventType:
+		event := containerEvent(msg)
+		switch {
+		case msg.Action == events.ActionStart && handlers.OnContainerStart != nil:
+			handlers.OnContainerStart(event)
+		case msg.Action == events.ActionDie && handlers.OnContainerDie != nil:
+			handlers.OnContainerDie(event)
+		case msg.Action == events.ActionOOM && handlers.OnContainerOOM != nil:
+			handlers.OnContainerOOM(event)
+		case msg.Action == events.ActionRestart && handlers.OnContainerRestart != nil:
+			handlers.OnContainerRestart(event)
+		case strings.HasPrefix(string(msg.Action), string(events.ActionHealthStatus)) && handlers.OnHealthStatus != nil:
+			_, status, _ := strings.Cut(string(msg.Action), ":")
+			handlers.OnHealthStatus(HealthEvent{ContainerEvent: event, Status: strings.TrimSpace(status)})
+		}
+	case events.ImageEventType:
+		event := ImageEvent{Ref: msg.Actor.ID, Action: msg.Action, Time: eventTime(msg)}
+		switch {
+		case msg.Action == events.ActionPull && handlers.OnImagePull != nil:
+			handlers.OnImagePull(event)
+		case msg.Action == events.ActionDelete && handlers.OnImageDelete != nil:
+			handlers.OnImageDelete(event)
+		}
+	}
+}
+
+// containerEvent converts a container event message, the name, image and exit code are actor attributes
+func containerEvent(msg events.Message) ContainerEvent {
+	event := ContainerEvent{
+		ID:         msg.Actor.ID,
+		Name:       msg.Actor.Attributes["name"],
+		Image:      msg.Actor.Attributes["image"],
+		Action:     msg.Action,
+		Time:       eventTime(msg),
+		Attributes: map[string]string{},
+	}
+	if code, ok := msg.Actor.Attributes["exitCode"]; ok {
+		event.ExitCode, _ = strconv.Atoi(code)
+	}
+	for key, value := range msg.Actor.Attributes {
+		if key != "name" && key != "image" && key != "exitCode" {
+			event.Attributes[key] = value
+		}
+	}
+	return event
+}
+
+// eventsSince formats a unix time in nanoseconds as the seconds.nanoseconds value of the events Since option
+func eventsSince(nanos int64) string {
+	return fmt.Sprintf("%d.%09d", nanos/int64(time.Second), nanos%int64(time.Second))
+}
+
+func eventTime(msg events.Message) time.Time {
+	if msg.TimeNano != 0 {
+		return time.Unix(0, msg.TimeNano)
+	}
+	return time.Unix(msg.Time, 0)
+}
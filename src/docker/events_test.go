@@ -0,0 +1,139 @@
+package docker_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+func containerMessage(action events.Action, attributes map[string]string, timeNano int64) events.Message {
+	return events.Message{
+		Type:     events.ContainerEventType,
+		Action:   action,
+		Actor:    events.Actor{ID: "abc123", Attributes: attributes},
+		TimeNano: timeNano,
+	}
+}
+
+func TestWatchEvents(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	messages, errs := eventStream(
+		containerMessage(events.ActionOOM, map[string]string{"name": "api", "image": "api:1.4"}, 1),
+		containerMessage(events.ActionDie, map[string]string{"name": "api", "exitCode": "137", "com.example.app": "shop", "execDuration": "12"}, 2),
+		containerMessage("health_status: unhealthy", map[string]string{"name": "api"}, 3),
+		events.Message{Type: events.ImageEventType, Action: events.ActionPull, Actor: events.Actor{ID: "nginx:1.27"}, TimeNano: 4},
+	)
+	args := filters.NewArgs(filters.Arg("type", "container"), filters.Arg("type", "image"))
+	mockClient.On("Events", mock.Anything, mock.MatchedBy(func(opts events.ListOptions) bool {
+		return opts.Filters.ExactMatch("type", "container") && opts.Filters.ExactMatch("type", "image") && opts.Since != ""
+	})).Return(messages, errs)
+
+	var (
+		oom    docker.ContainerEvent
+		die    docker.ContainerEvent
+		health docker.HealthEvent
+		pulled docker.ImageEvent
+		count  int
+	)
+	err := d.WatchEvents(ctx, docker.EventWatchOptions{Filters: args}, docker.EventHandlers{
+		OnContainerOOM: func(e docker.ContainerEvent) { oom = e },
+		OnContainerDie: func(e docker.ContainerEvent) { die = e },
+		OnHealthStatus: func(e docker.HealthEvent) { health = e },
+		OnImagePull: func(e docker.ImageEvent) {
+			pulled = e
+			cancel()
+		},
+		OnEvent: func(events.Message) { count++ },
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "api", oom.Name)
+	assert.Equal(t, "api:1.4", oom.Image)
+	assert.Equal(t, 137, die.ExitCode)
+	assert.Equal(t, map[string]string{"com.example.app": "shop", "execDuration": "12"}, die.Attributes)
+	assert.Equal(t, "unhealthy", health.Status)
+	assert.Equal(t, "api", health.Name)
+	assert.Equal(t, "nginx:1.27", pulled.Ref)
+	assert.Equal(t, 4, count)
+}
+
+func TestWatchEventsReconnectsBeforeFirstEvent(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	firstErrs := make(chan error, 1)
+	firstErrs <- errors.New("unexpected EOF")
+	second, secondErrs := eventStream(containerMessage(events.ActionStart, nil, 1))
+
+	// Both subscriptions start at the time of the first one, so events sent while reconnecting are replayed
+	mockClient.On("Events", mock.Anything, mock.Anything).Return(make(chan events.Message), firstErrs).Once()
+	mockClient.On("Events", mock.Anything, mock.Anything).Return(second, secondErrs).Once()
+
+	started := time.Now()
+	err := d.WatchEvents(ctx, docker.EventWatchOptions{ReconnectDelay: time.Millisecond}, docker.EventHandlers{
+		OnContainerStart: func(docker.ContainerEvent) { cancel() },
+	})
+	require.NoError(t, err)
+
+	mockClient.AssertExpectations(t)
+	require.Len(t, mockClient.Calls, 2)
+	first := mockClient.Calls[0].Arguments.Get(1).(events.ListOptions).Since
+	assert.Equal(t, first, mockClient.Calls[1].Arguments.Get(1).(events.ListOptions).Since)
+	seconds, err := strconv.ParseFloat(first, 64)
+	require.NoError(t, err)
+	assert.InDelta(t, float64(started.Unix()), seconds, 5)
+}
+
+func TestWatchEventsReconnects(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	first, _ := eventStream(containerMessage(events.ActionStart, nil, 1720000000000000000))
+	firstErrs := make(chan error, 1)
+	second, secondErrs := eventStream(containerMessage(events.ActionRestart, map[string]string{"name": "worker"}, 1720000005000000000))
+
+	mockClient.On("Events", mock.Anything, mock.MatchedBy(func(opts events.ListOptions) bool {
+		return opts.Since != "" && opts.Since != "1720000000.000000001"
+	})).Return(first, firstErrs).Once()
+	mockClient.On("Events", mock.Anything, mock.MatchedBy(func(opts events.ListOptions) bool {
+		return opts.Since == "1720000000.000000001"
+	})).Return(second, secondErrs).Once()
+
+	var (
+		reconnectErr error
+		restarted    string
+	)
+	err := d.WatchEvents(ctx, docker.EventWatchOptions{ReconnectDelay: time.Millisecond}, docker.EventHandlers{
+		OnContainerStart: func(docker.ContainerEvent) {
+			firstErrs <- errors.New("unexpected EOF")
+		},
+		OnReconnect: func(err error) { reconnectErr = err },
+		OnContainerRestart: func(e docker.ContainerEvent) {
+			restarted = e.Name
+			cancel()
+		},
+	})
+	require.NoError(t, err)
+
+	assert.ErrorContains(t, reconnectErr, "unexpected EOF")
+	assert.Equal(t, "worker", restarted)
+	mockClient.AssertExpectations(t)
+}
@@ -0,0 +1,114 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"io"
+	"time"
+)
+
+// execInspectInterval is how often Exec checks whether a finished command has reported its exit code
+const execInspectInterval = 50 * time.Millisecond
+
+// ExecOptions configures a command run inside a running container
+type ExecOptions struct {
+	Cmd        []string
+	Env        []string
+	WorkingDir string
+	User       string
+	// Stdin is streamed to the command and closed once it is fully read
+	Stdin io.Reader
+	// Timeout stops waiting for the command after the given duration, zero waits until it exits
+	Timeout time.Duration
+}
+
+// ExecResult holds the output and exit code of a command run with Exec
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Exec runs a command inside a running container and waits for it to exit
+// A non-zero exit code is not an error, callers decide how to handle it using ExecResult.ExitCode
+func (d *Docker) Exec(containerID string, opts ExecOptions) (ExecResult, error) {
+	var result ExecResult
+	if len(opts.Cmd) == 0 {
+		return result, fmt.Errorf("exec command is empty")
+	}
+
+	ctx := d.baseContext()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	created, err := d.Client.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          opts.Cmd,
+		Env:          opts.Env,
+		WorkingDir:   opts.WorkingDir,
+		User:         opts.User,
+		AttachStdin:  opts.Stdin != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return result, fmt.Errorf("could not create exec in container %s: %w", containerID, err)
+	}
+
+	resp, err := d.Client.ContainerExecAttach(ctx, created.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return result, fmt.Errorf("could not attach to exec in container %s: %w", containerID, err)
+	}
+	defer resp.Close()
+
+	if opts.Stdin != nil {
+		go func() {
+			_, _ = io.Copy(resp.Conn, opts.Stdin)
+			_ = resp.CloseWrite()
+		}()
+	}
+
+	var stdout, stderr bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(&stdout, &stderr, resp.Reader)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return result, fmt.Errorf("could not read exec output: %w", err)
+		}
+	case <-ctx.Done():
+		// Closing the connection unblocks StdCopy, wait for it so the buffers are no longer written to
+		resp.Close()
+		<-done
+		result.Stdout, result.Stderr = stdout.String(), stderr.String()
+		return result, fmt.Errorf("exec %v in container %s did not finish: %w", opts.Cmd, containerID, ctx.Err())
+	}
+	result.Stdout, result.Stderr = stdout.String(), stderr.String()
+
+	// The output stream may close slightly before the daemon records the exit code
+	for {
+		inspect, err := d.Client.ContainerExecInspect(ctx, created.ID)
+		if err != nil {
+			return result, fmt.Errorf("could not inspect exec in container %s: %w", containerID, err)
+		}
+		if !inspect.Running {
+			result.ExitCode = inspect.ExitCode
+			return result, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, fmt.Errorf("exec %v in container %s did not finish: %w", opts.Cmd, containerID, ctx.Err())
+		case <-time.After(execInspectInterval):
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package docker_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+// hijackedConn returns a hijacked response over a loopback tcp connection and the daemon side of it
+func hijackedConn(t *testing.T) (types.HijackedResponse, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	require.NoError(t, err)
+	daemon := <-accepted
+	t.Cleanup(func() { _ = daemon.Close() })
+
+	return types.HijackedResponse{Conn: conn, Reader: bufio.NewReader(conn)}, daemon
+}
+
+func TestExec(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	resp, daemon := hijackedConn(t)
+	// The fake daemon echoes stdin to stdout and writes a warning to stderr, like `tee /dev/stdout`
+	go func() {
+		input, _ := io.ReadAll(daemon)
+		_, _ = stdcopy.NewStdWriter(daemon, stdcopy.Stdout).Write(input)
+		_, _ = stdcopy.NewStdWriter(daemon, stdcopy.Stderr).Write([]byte("warning: cache is cold\n"))
+		_ = daemon.Close()
+	}()
+
+	mockClient.On("ContainerExecCreate", mock.Anything, "api", mock.MatchedBy(func(opts container.ExecOptions) bool {
+		return opts.AttachStdin && opts.AttachStdout && opts.AttachStderr && opts.User == "app"
+	})).Return(types.IDResponse{ID: "exec1"}, nil)
+	mockClient.On("ContainerExecAttach", mock.Anything, "exec1", mock.Anything).Return(resp, nil)
+	mockClient.On("ContainerExecInspect", mock.Anything, "exec1").Return(container.ExecInspect{ExitCode: 3}, nil)
+
+	result, err := d.Exec("api", docker.ExecOptions{
+		Cmd:   []string{"./manage", "migrate"},
+		User:  "app",
+		Stdin: strings.NewReader("yes\n"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "yes\n", result.Stdout)
+	assert.Equal(t, "warning: cache is cold\n", result.Stderr)
+	assert.Equal(t, 3, result.ExitCode)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestExecTimeout(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	// Without Ctx the timeout is derived from context.Background()
+	d := docker.Docker{Client: mockClient}
+
+	// The fake daemon never answers
+	resp, _ := hijackedConn(t)
+	mockClient.On("ContainerExecCreate", mock.Anything, "api", mock.Anything).Return(types.IDResponse{ID: "exec1"}, nil)
+	mockClient.On("ContainerExecAttach", mock.Anything, "exec1", mock.Anything).Return(resp, nil)
+
+	_, err := d.Exec("api", docker.ExecOptions{Cmd: []string{"sleep", "60"}, Timeout: 50 * time.Millisecond})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	mockClient.AssertNotCalled(t, "ContainerExecInspect", mock.Anything, mock.Anything)
+}
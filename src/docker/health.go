@@ -0,0 +1,97 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"strconv"
+	"time"
+)
+
+// ErrContainerNotHealthy is returned by WaitForHealthy when the container did not reach the healthy state
+var ErrContainerNotHealthy = errors.New("container did not become healthy")
+
+// ContainerHealthState is the state WaitForHealthy stopped waiting in
+type ContainerHealthState string
+
+const (
+	ContainerHealthy   ContainerHealthState = "healthy"
+	ContainerUnhealthy ContainerHealthState = "unhealthy"
+	ContainerExited    ContainerHealthState = "exited"
+	ContainerTimedOut  ContainerHealthState = "timed_out"
+)
+
+// HealthResult holds the outcome of WaitForHealthy, Logs holds the last health check results when the container is not healthy
+type HealthResult struct {
+	State    ContainerHealthState
+	ExitCode int
+	Logs     []*types.HealthcheckResult
+}
+
+// WaitForHealthy blocks until the container becomes healthy, unhealthy, exits or timeout passes
+// It is driven by the docker events stream, the container must define a healthcheck
+// Every state except healthy returns ErrContainerNotHealthy along with the last health check logs
+func (d *Docker) WaitForHealthy(containerID string, timeout time.Duration) (HealthResult, error) {
+	ctx, cancel := context.WithTimeout(d.baseContext(), timeout)
+	defer cancel()
+
+	// Subscribe before inspecting so a state change between the two calls is not missed
+	messages, errs := d.Client.Events(ctx, events.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("container", containerID),
+		),
+	})
+
+	info, err := d.Client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return HealthResult{}, fmt.Errorf("could not inspect container %s: %w", containerID, err)
+	}
+	if info.State == nil || info.State.Health == nil {
+		return HealthResult{}, fmt.Errorf("container %s has no healthcheck", containerID)
+	}
+
+	switch {
+	case !info.State.Running:
+		return d.healthFailure(containerID, HealthResult{State: ContainerExited, ExitCode: info.State.ExitCode})
+	case info.State.Health.Status == types.Healthy:
+		return HealthResult{State: ContainerHealthy}, nil
+	case info.State.Health.Status == types.Unhealthy:
+		return d.healthFailure(containerID, HealthResult{State: ContainerUnhealthy})
+	}
+
+	for {
+		select {
+		case msg := <-messages:
+			switch msg.Action {
+			case events.ActionHealthStatusHealthy:
+				return HealthResult{State: ContainerHealthy}, nil
+			case events.ActionHealthStatusUnhealthy:
+				return d.healthFailure(containerID, HealthResult{State: ContainerUnhealthy})
+			case events.ActionDie:
+				exitCode, _ := strconv.Atoi(msg.Actor.Attributes["exitCode"])
+				return d.healthFailure(containerID, HealthResult{State: ContainerExited, ExitCode: exitCode})
+			}
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return d.healthFailure(containerID, HealthResult{State: ContainerTimedOut})
+			}
+			return HealthResult{}, fmt.Errorf("could not read docker events: %w", err)
+		case <-ctx.Done():
+			return d.healthFailure(containerID, HealthResult{State: ContainerTimedOut})
+		}
+	}
+}
+
+// healthFailure adds the last health check logs to result and returns it with ErrContainerNotHealthy
+func (d *Docker) healthFailure(containerID string, result HealthResult) (HealthResult, error) {
+	// The base context is used since the wait context may already be expired
+	info, err := d.Client.ContainerInspect(d.baseContext(), containerID)
+	if err == nil && info.State != nil && info.State.Health != nil {
+		result.Logs = info.State.Health.Log
+	}
+	return result, fmt.Errorf("%w: container %s is %s", ErrContainerNotHealthy, containerID, result.State)
+}
@@ -0,0 +1,123 @@
+package docker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+func healthJSON(running bool, status string, logs ...*types.HealthcheckResult) types.ContainerJSON {
+	return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+		State: &types.ContainerState{Running: running, Health: &types.Health{Status: status, Log: logs}},
+	}}
+}
+
+func eventStream(msgs ...events.Message) (chan events.Message, chan error) {
+	messages := make(chan events.Message, len(msgs))
+	for _, msg := range msgs {
+		messages <- msg
+	}
+	return messages, make(chan error)
+}
+
+func TestWaitForHealthy(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	messages, errs := eventStream(
+		events.Message{Action: events.ActionStart},
+		events.Message{Action: events.ActionHealthStatusHealthy},
+	)
+	mockClient.On("Events", mock.Anything, mock.MatchedBy(func(opts events.ListOptions) bool {
+		return opts.Filters.ExactMatch("container", "web") && opts.Filters.ExactMatch("type", "container")
+	})).Return(messages, errs)
+	mockClient.On("ContainerInspect", mock.Anything, "web").Return(healthJSON(true, types.Starting), nil)
+
+	result, err := d.WaitForHealthy("web", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, docker.ContainerHealthy, result.State)
+}
+
+func TestWaitForHealthyUnhealthy(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	// Without a context the health check logs must still be read
+	d := docker.Docker{Client: mockClient}
+	hasContext := mock.MatchedBy(func(ctx context.Context) bool { return ctx != nil })
+
+	check := &types.HealthcheckResult{ExitCode: 1, Output: "curl: (7) Failed to connect"}
+	messages, errs := eventStream(events.Message{Action: events.ActionHealthStatusUnhealthy})
+	mockClient.On("Events", mock.Anything, mock.Anything).Return(messages, errs)
+	mockClient.On("ContainerInspect", hasContext, "web").Return(healthJSON(true, types.Starting), nil).Once()
+	mockClient.On("ContainerInspect", hasContext, "web").Return(healthJSON(true, types.Unhealthy, check), nil).Once()
+
+	result, err := d.WaitForHealthy("web", time.Second)
+	assert.ErrorIs(t, err, docker.ErrContainerNotHealthy)
+	assert.Equal(t, docker.ContainerUnhealthy, result.State)
+	require.Len(t, result.Logs, 1)
+	assert.Equal(t, "curl: (7) Failed to connect", result.Logs[0].Output)
+}
+
+func TestWaitForHealthyExited(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	messages, errs := eventStream(events.Message{
+		Action: events.ActionDie,
+		Actor:  events.Actor{ID: "web", Attributes: map[string]string{"exitCode": "137"}},
+	})
+	mockClient.On("Events", mock.Anything, mock.Anything).Return(messages, errs)
+	mockClient.On("ContainerInspect", mock.Anything, "web").Return(healthJSON(true, types.Starting), nil)
+
+	result, err := d.WaitForHealthy("web", time.Second)
+	assert.ErrorIs(t, err, docker.ErrContainerNotHealthy)
+	assert.Equal(t, docker.ContainerExited, result.State)
+	assert.Equal(t, 137, result.ExitCode)
+}
+
+func TestWaitForHealthyTimeout(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	messages, errs := eventStream()
+	mockClient.On("Events", mock.Anything, mock.Anything).Return(messages, errs)
+	mockClient.On("ContainerInspect", mock.Anything, "web").Return(healthJSON(true, types.Starting), nil)
+
+	result, err := d.WaitForHealthy("web", 20*time.Millisecond)
+	assert.ErrorIs(t, err, docker.ErrContainerNotHealthy)
+	assert.Equal(t, docker.ContainerTimedOut, result.State)
+}
+
+func TestWaitForHealthyAlreadyHealthy(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	messages, errs := eventStream()
+	mockClient.On("Events", mock.Anything, mock.Anything).Return(messages, errs)
+	mockClient.On("ContainerInspect", mock.Anything, "web").Return(healthJSON(true, types.Healthy), nil)
+
+	result, err := d.WaitForHealthy("web", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, docker.ContainerHealthy, result.State)
+}
+
+func TestWaitForHealthyWithoutHealthcheck(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	messages, errs := eventStream()
+	mockClient.On("Events", mock.Anything, mock.Anything).Return(messages, errs)
+	mockClient.On("ContainerInspect", mock.Anything, "web").Return(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{
+		State: &types.ContainerState{Running: true},
+	}}, nil)
+
+	_, err := d.WaitForHealthy("web", time.Second)
+	assert.ErrorContains(t, err, "has no healthcheck")
+}
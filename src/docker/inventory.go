@@ -0,0 +1,187 @@
+package docker
+
+import (
+	"fmt"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"sort"
+	"time"
+)
+
+// ImageInfo describes a local image, Containers is the number of containers created from it including stopped ones
+type ImageInfo struct {
+	ID          string
+	RepoTags    []string
+	RepoDigests []string
+	Size        int64
+	Created     time.Time
+	Containers  int
+}
+
+// ExpectedImage is an image a host should have, Digest is optional and pins the exact image the tag must point to
+type ExpectedImage struct {
+	Ref    string
+	Digest string
+}
+
+// OutdatedImage is an expected image present locally under its tag but with a different digest
+type OutdatedImage struct {
+	Ref            string
+	ImageID        string
+	LocalDigests   []string
+	ExpectedDigest string
+}
+
+// ImageDriftReport compares local images with the expected ones
+type ImageDriftReport struct {
+	// Missing holds expected refs that are not present locally
+	Missing []string
+	// Outdated holds expected refs whose local image doesn't match the expected digest
+	Outdated []OutdatedImage
+	// Unused holds local images that are not expected and not used by any container, they are what PruneAll would reclaim
+	Unused []ImageInfo
+	// UpToDate holds expected refs that are present with the expected digest, or present at all when no digest is known
+	UpToDate []string
+}
+
+// InSync reports whether no expected image is missing or outdated, unused images don't count as drift
+func (r ImageDriftReport) InSync() bool {
+	return len(r.Missing) == 0 && len(r.Outdated) == 0
+}
+
+// ImageInventory lists local images with the number of containers using each of them
+func (d *Docker) ImageInventory() ([]ImageInfo, error) {
+	images, err := d.Client.ImageList(d.Ctx, image.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("could not list images: %w", err)
+	}
+
+	containers, err := d.Client.ContainerList(d.Ctx, container.ListOptions{All: true})
+	if err != nil {
+		return nil, fmt.Errorf("could not list containers: %w", err)
+	}
+	usage := map[string]int{}
+	for _, c := range containers {
+		usage[c.ImageID]++
+	}
+
+	inventory := make([]ImageInfo, 0, len(images))
+	for _, img := range images {
+		inventory = append(inventory, ImageInfo{
+			ID:          img.ID,
+			RepoTags:    img.RepoTags,
+			RepoDigests: img.RepoDigests,
+			Size:        img.Size,
+			Created:     time.Unix(img.Created, 0),
+			Containers:  usage[img.ID],
+		})
+	}
+	sort.Slice(inventory, func(i, j int) bool { return inventory[i].Created.After(inventory[j].Created) })
+
+	return inventory, nil
+}
+
+// ImageDrift compares local images with the expected ones
+// When checkRegistry is set, expected images without a Digest are compared with the digest their tag currently has in the registry
+func (d *Docker) ImageDrift(expected []ExpectedImage, checkRegistry bool) (ImageDriftReport, error) {
+	var report ImageDriftReport
+
+	inventory, err := d.ImageInventory()
+	if err != nil {
+		return report, err
+	}
+
+	byTag := map[string]*ImageInfo{}
+	byDigest := map[string]*ImageInfo{}
+	for i := range inventory {
+		for _, tag := range inventory[i].RepoTags {
+			byTag[normalizeImageRef(tag)] = &inventory[i]
+		}
+		for _, digest := range inventory[i].RepoDigests {
+			byDigest[normalizeImageRef(digest)] = &inventory[i]
+		}
+	}
+
+	expectedIDs := map[string]bool{}
+	for _, e := range expected {
+		named, err := reference.ParseNormalizedNamed(e.Ref)
+		if err != nil {
+			return report, fmt.Errorf("invalid image reference %s: %w", e.Ref, err)
+		}
+
+		// Refs with a digest are pinned already, they are either present or missing
+		if canonical, ok := named.(reference.Canonical); ok {
+			info, found := byDigest[reference.TrimNamed(canonical).String()+"@"+canonical.Digest().String()]
+			if !found {
+				report.Missing = append(report.Missing, e.Ref)
+				continue
+			}
+			expectedIDs[info.ID] = true
+			report.UpToDate = append(report.UpToDate, e.Ref)
+			continue
+		}
+
+		info, found := byTag[reference.TagNameOnly(named).String()]
+		if !found {
+			report.Missing = append(report.Missing, e.Ref)
+			continue
+		}
+		expectedIDs[info.ID] = true
+
+		digest := e.Digest
+		if digest == "" && checkRegistry {
+			pinned, err := d.ResolveImageDigest(e.Ref)
+			if err != nil {
+				return report, err
+			}
+			digest = pinned.Digest
+		}
+		if digest == "" {
+			report.UpToDate = append(report.UpToDate, e.Ref)
+			continue
+		}
+
+		if match, ok := byDigest[named.Name()+"@"+digest]; ok && match.ID == info.ID {
+			report.UpToDate = append(report.UpToDate, e.Ref)
+			continue
+		}
+		report.Outdated = append(report.Outdated, OutdatedImage{
+			Ref:            e.Ref,
+			ImageID:        info.ID,
+			LocalDigests:   info.RepoDigests,
+			ExpectedDigest: digest,
+		})
+	}
+
+	for _, info := range inventory {
+		if info.Containers == 0 && !expectedIDs[info.ID] {
+			report.Unused = append(report.Unused, info)
+		}
+	}
+
+	return report, nil
+}
+
+// distributionInspect asks the registry for the manifest descriptor of imageRef using the credentials stored for its registry
+func (d *Docker) distributionInspect(imageRef string) (registry.DistributionInspect, error) {
+	auth, err := d.registryAuth(imageRef)
+	if err != nil {
+		return registry.DistributionInspect{}, err
+	}
+	inspect, err := d.Client.DistributionInspect(d.Ctx, imageRef, auth)
+	if err != nil {
+		return registry.DistributionInspect{}, fmt.Errorf("could not inspect %s in registry: %w", imageRef, err)
+	}
+	return inspect, nil
+}
+
+// normalizeImageRef expands a familiar reference such as nginx:1.27 to docker.io/library/nginx:1.27, invalid refs such as <none>:<none> are returned as is
+func normalizeImageRef(ref string) string {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return ref
+	}
+	return named.String()
+}
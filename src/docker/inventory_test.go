@@ -0,0 +1,89 @@
+package docker_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+const (
+	nginxDigest = "sha256:0a1e3b2f5c8d7e6f9a0b1c2d3e4f5a6b7c8d9e0f1a2b3c4d5e6f7a8b9c0d1e2f"
+	newDigest   = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+)
+
+func inventoryMocks(mockClient *MockDockerClient) {
+	mockClient.On("ImageList", mock.Anything, image.ListOptions{}).Return([]image.Summary{
+		{ID: "sha256:nginx", RepoTags: []string{"nginx:1.27"}, RepoDigests: []string{"nginx@" + nginxDigest}, Size: 188, Created: 1720000000},
+		{ID: "sha256:api", RepoTags: []string{"registry.example.com/shop/api:v1"}, Size: 42, Created: 1720100000},
+		{ID: "sha256:old", RepoTags: []string{"registry.example.com/shop/api:v0"}, Size: 40, Created: 1710000000},
+	}, nil)
+	mockClient.On("ContainerList", mock.Anything, container.ListOptions{All: true}).Return([]types.Container{
+		{ID: "web", ImageID: "sha256:nginx"},
+		{ID: "web-old", ImageID: "sha256:nginx"},
+	}, nil)
+}
+
+func TestImageInventory(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+	inventoryMocks(mockClient)
+
+	inventory, err := d.ImageInventory()
+	require.NoError(t, err)
+	require.Len(t, inventory, 3)
+
+	// Newest first
+	assert.Equal(t, "sha256:api", inventory[0].ID)
+	assert.Equal(t, "sha256:nginx", inventory[1].ID)
+	assert.Equal(t, 2, inventory[1].Containers)
+	assert.Equal(t, []string{"nginx@" + nginxDigest}, inventory[1].RepoDigests)
+}
+
+func TestImageDrift(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+	inventoryMocks(mockClient)
+
+	report, err := d.ImageDrift([]docker.ExpectedImage{
+		{Ref: "docker.io/library/nginx:1.27", Digest: nginxDigest},
+		{Ref: "registry.example.com/shop/api:v1", Digest: newDigest},
+		{Ref: "redis@" + newDigest},
+		{Ref: "postgres:16"},
+	}, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"docker.io/library/nginx:1.27"}, report.UpToDate)
+	assert.Equal(t, []string{"redis@" + newDigest, "postgres:16"}, report.Missing)
+	require.Len(t, report.Outdated, 1)
+	assert.Equal(t, "sha256:api", report.Outdated[0].ImageID)
+	assert.Equal(t, newDigest, report.Outdated[0].ExpectedDigest)
+	require.Len(t, report.Unused, 1)
+	assert.Equal(t, "sha256:old", report.Unused[0].ID)
+	assert.False(t, report.InSync())
+}
+
+func TestImageDriftChecksRegistry(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+	inventoryMocks(mockClient)
+
+	mockClient.On("DistributionInspect", mock.Anything, "nginx:1.27", "").Return(registry.DistributionInspect{
+		Descriptor: ocispec.Descriptor{Digest: newDigest},
+	}, nil)
+
+	report, err := d.ImageDrift([]docker.ExpectedImage{{Ref: "nginx:1.27"}}, true)
+	require.NoError(t, err)
+	require.Len(t, report.Outdated, 1)
+	assert.Equal(t, "nginx:1.27", report.Outdated[0].Ref)
+	assert.Equal(t, newDigest, report.Outdated[0].ExpectedDigest)
+}
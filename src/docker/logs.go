@@ -0,0 +1,171 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/pkg/stdcopy"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogStream is the output stream a log line was written to
+type LogStream string
+
+const (
+	Stdout LogStream = "stdout"
+	Stderr LogStream = "stderr"
+)
+
+// LogOptions limits which log lines are returned, empty fields return the whole log
+type LogOptions struct {
+	// Since and Until accept RFC3339 timestamps, unix timestamps or durations relative to now such as 10m
+	Since string
+	Until string
+	// Tail is the number of lines to return from the end of the log or "all"
+	Tail string
+}
+
+// LogLine is a single demultiplexed log line
+type LogLine struct {
+	ContainerID string
+	Stream      LogStream
+	Timestamp   time.Time
+	Text        string
+}
+
+// ContainerLogs returns the log lines of a container
+func (d *Docker) ContainerLogs(containerID string, opts LogOptions) ([]LogLine, error) {
+	var lines []LogLine
+	err := d.streamLogs(d.Ctx, containerID, opts, false, func(line LogLine) {
+		lines = append(lines, line)
+	})
+	return lines, err
+}
+
+// FollowContainerLogs calls handler for every log line of a container until ctx is cancelled or the container stops
+func (d *Docker) FollowContainerLogs(ctx context.Context, containerID string, opts LogOptions, handler func(LogLine)) error {
+	err := d.streamLogs(ctx, containerID, opts, true, handler)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+// FollowLogsByLabel follows the logs of every running container with the given label, label is "key" or "key=value"
+// handler is never called concurrently, it returns once all containers stopped or ctx is cancelled
+func (d *Docker) FollowLogsByLabel(ctx context.Context, label string, opts LogOptions, handler func(LogLine)) error {
+	containers, err := d.Client.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", label)),
+	})
+	if err != nil {
+		return fmt.Errorf("could not list containers with label %s: %w", label, err)
+	}
+	if len(containers) == 0 {
+		return fmt.Errorf("no running containers with label %s", label)
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make([]error, len(containers))
+	)
+	for i, c := range containers {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			errs[i] = d.FollowContainerLogs(ctx, id, opts, func(line LogLine) {
+				mu.Lock()
+				defer mu.Unlock()
+				handler(line)
+			})
+		}(i, c.ID)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// streamLogs reads container logs and calls handler for every complete line
+func (d *Docker) streamLogs(ctx context.Context, containerID string, opts LogOptions, follow bool, handler func(LogLine)) error {
+	info, err := d.Client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("could not inspect container %s: %w", containerID, err)
+	}
+
+	out, err := d.Client.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Since:      opts.Since,
+		Until:      opts.Until,
+		Tail:       opts.Tail,
+		Follow:     follow,
+		Timestamps: true,
+	})
+	if err != nil {
+		return fmt.Errorf("could not get logs of container %s: %w", containerID, err)
+	}
+	defer out.Close()
+
+	stdout := &logLineWriter{containerID: containerID, stream: Stdout, handler: handler}
+	stderr := &logLineWriter{containerID: containerID, stream: Stderr, handler: handler}
+
+	// Containers with a tty have a single raw stream, the others multiplex stdout and stderr
+	if info.Config != nil && info.Config.Tty {
+		_, err = io.Copy(stdout, out)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, out)
+	}
+	stdout.flush()
+	stderr.flush()
+	if err != nil {
+		return fmt.Errorf("could not read logs of container %s: %w", containerID, err)
+	}
+
+	return nil
+}
+
+// logLineWriter splits written data into lines and parses the timestamp docker prefixes every line with
+type logLineWriter struct {
+	containerID string
+	stream      LogStream
+	handler     func(LogLine)
+	buf         bytes.Buffer
+}
+
+func (w *logLineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			break
+		}
+		line := string(w.buf.Next(i + 1))
+		w.emit(strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"))
+	}
+	return len(p), nil
+}
+
+// flush emits the last line if the log didn't end with a newline
+func (w *logLineWriter) flush() {
+	if w.buf.Len() > 0 {
+		w.emit(w.buf.String())
+		w.buf.Reset()
+	}
+}
+
+func (w *logLineWriter) emit(raw string) {
+	line := LogLine{ContainerID: w.containerID, Stream: w.stream, Text: raw}
+	if ts, text, ok := strings.Cut(raw, " "); ok {
+		if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			line.Timestamp = parsed
+			line.Text = text
+		}
+	}
+	w.handler(line)
+}
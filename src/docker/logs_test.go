@@ -0,0 +1,98 @@
+package docker_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+// multiplexedLogs builds a log stream in the format docker uses for containers without a tty
+func multiplexedLogs(t *testing.T, stdout, stderr string) io.ReadCloser {
+	var buf bytes.Buffer
+	_, err := stdcopy.NewStdWriter(&buf, stdcopy.Stdout).Write([]byte(stdout))
+	require.NoError(t, err)
+	_, err = stdcopy.NewStdWriter(&buf, stdcopy.Stderr).Write([]byte(stderr))
+	require.NoError(t, err)
+	return io.NopCloser(&buf)
+}
+
+func containerJSON(tty bool) types.ContainerJSON {
+	return types.ContainerJSON{Config: &container.Config{Tty: tty}}
+}
+
+func TestContainerLogs(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("ContainerInspect", mock.Anything, "web").Return(containerJSON(false), nil)
+	mockClient.On("ContainerLogs", mock.Anything, "web", mock.MatchedBy(func(opts container.LogsOptions) bool {
+		return opts.Timestamps && opts.Tail == "50" && opts.Since == "10m" && !opts.Follow
+	})).Return(multiplexedLogs(t,
+		"2024-07-01T10:00:00.000000001Z listening on :8080\n2024-07-01T10:00:01Z ready\n",
+		"2024-07-01T10:00:02Z connection refused\n",
+	), nil)
+
+	lines, err := d.ContainerLogs("web", docker.LogOptions{Since: "10m", Tail: "50"})
+	require.NoError(t, err)
+	require.Len(t, lines, 3)
+
+	assert.Equal(t, docker.Stdout, lines[0].Stream)
+	assert.Equal(t, "listening on :8080", lines[0].Text)
+	assert.Equal(t, time.Date(2024, 7, 1, 10, 0, 0, 1, time.UTC), lines[0].Timestamp)
+	assert.Equal(t, docker.Stderr, lines[2].Stream)
+	assert.Equal(t, "connection refused", lines[2].Text)
+	assert.Equal(t, "web", lines[2].ContainerID)
+
+	mockClient.AssertExpectations(t)
+}
+
+func TestContainerLogsWithTTY(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("ContainerInspect", mock.Anything, "shell").Return(containerJSON(true), nil)
+	mockClient.On("ContainerLogs", mock.Anything, "shell", mock.Anything).
+		Return(io.NopCloser(bytes.NewBufferString("2024-07-01T10:00:00Z $ echo hi\r\n2024-07-01T10:00:01Z hi")), nil)
+
+	lines, err := d.ContainerLogs("shell", docker.LogOptions{})
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "$ echo hi", lines[0].Text)
+	assert.Equal(t, "hi", lines[1].Text)
+	assert.Equal(t, docker.Stdout, lines[1].Stream)
+}
+
+func TestFollowLogsByLabel(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("ContainerList", mock.Anything, mock.Anything).Return([]types.Container{{ID: "api-1"}, {ID: "api-2"}}, nil)
+	mockClient.On("ContainerInspect", mock.Anything, mock.Anything).Return(containerJSON(false), nil)
+	mockClient.On("ContainerLogs", mock.Anything, "api-1", mock.MatchedBy(func(opts container.LogsOptions) bool { return opts.Follow })).
+		Return(multiplexedLogs(t, "2024-07-01T10:00:00Z started api-1\n", ""), nil)
+	mockClient.On("ContainerLogs", mock.Anything, "api-2", mock.MatchedBy(func(opts container.LogsOptions) bool { return opts.Follow })).
+		Return(multiplexedLogs(t, "", "2024-07-01T10:00:00Z panic in api-2\n"), nil)
+
+	var lines []docker.LogLine
+	err := d.FollowLogsByLabel(context.Background(), "role=api", docker.LogOptions{Tail: "10"}, func(line docker.LogLine) {
+		lines = append(lines, line)
+	})
+	require.NoError(t, err)
+
+	require.Len(t, lines, 2)
+	texts := []string{lines[0].Text, lines[1].Text}
+	assert.ElementsMatch(t, []string{"started api-1", "panic in api-2"}, texts)
+
+	mockClient.AssertExpectations(t)
+}
@@ -0,0 +1,115 @@
+package docker
+
+import (
+	"fmt"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+)
+
+// NetworkOptions configures a network created by EnsureNetwork
+type NetworkOptions struct {
+	// Driver defaults to bridge on the daemon side
+	Driver     string
+	Internal   bool
+	Attachable bool
+	Options    map[string]string
+	Labels     map[string]string
+	// Subnet and Gateway are optional, the daemon picks a free subnet when Subnet is empty
+	Subnet  string
+	Gateway string
+}
+
+// EnsureNetwork creates the network if it doesn't exist and returns its ID
+// An existing network is left untouched unless it uses a different driver than opts.Driver, which is an error
+func (d *Docker) EnsureNetwork(name string, opts NetworkOptions) (string, error) {
+	existing, err := d.Client.NetworkInspect(d.Ctx, name, network.InspectOptions{})
+	if err == nil {
+		if opts.Driver != "" && existing.Driver != "" && existing.Driver != opts.Driver {
+			return "", fmt.Errorf("network %s already exists with driver %s instead of %s", name, existing.Driver, opts.Driver)
+		}
+		return existing.ID, nil
+	}
+	if !errdefs.IsNotFound(err) {
+		return "", fmt.Errorf("could not inspect network %s: %w", name, err)
+	}
+
+	createOpts := network.CreateOptions{
+		Driver:     opts.Driver,
+		Internal:   opts.Internal,
+		Attachable: opts.Attachable,
+		Options:    opts.Options,
+		Labels:     opts.Labels,
+	}
+	if opts.Subnet != "" {
+		createOpts.IPAM = &network.IPAM{Config: []network.IPAMConfig{{Subnet: opts.Subnet, Gateway: opts.Gateway}}}
+	}
+	created, err := d.Client.NetworkCreate(d.Ctx, name, createOpts)
+	if err != nil {
+		return "", fmt.Errorf("could not create network %s: %w", name, err)
+	}
+
+	return created.ID, nil
+}
+
+// InspectNetwork returns the details of a network, including the containers connected to it
+func (d *Docker) InspectNetwork(name string) (network.Inspect, error) {
+	info, err := d.Client.NetworkInspect(d.Ctx, name, network.InspectOptions{})
+	if err != nil {
+		return network.Inspect{}, fmt.Errorf("could not inspect network %s: %w", name, err)
+	}
+	return info, nil
+}
+
+// ConnectNetwork connects a container to a network with optional DNS aliases, it does nothing if the container is already connected
+func (d *Docker) ConnectNetwork(networkName, containerID string, aliases ...string) error {
+	connected, err := d.connectedToNetwork(networkName, containerID)
+	if err != nil || connected {
+		return err
+	}
+
+	err = d.Client.NetworkConnect(d.Ctx, networkName, containerID, &network.EndpointSettings{Aliases: aliases})
+	if err != nil {
+		return fmt.Errorf("could not connect container %s to network %s: %w", containerID, networkName, err)
+	}
+	return nil
+}
+
+// DisconnectNetwork disconnects a container from a network, it does nothing if the container is not connected
+func (d *Docker) DisconnectNetwork(networkName, containerID string) error {
+	connected, err := d.connectedToNetwork(networkName, containerID)
+	if err != nil || !connected {
+		return err
+	}
+
+	err = d.Client.NetworkDisconnect(d.Ctx, networkName, containerID, false)
+	if err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("could not disconnect container %s from network %s: %w", containerID, networkName, err)
+	}
+	return nil
+}
+
+// RemoveNetwork removes a network, a network that doesn't exist is not an error
+func (d *Docker) RemoveNetwork(name string) error {
+	err := d.Client.NetworkRemove(d.Ctx, name)
+	if err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("could not remove network %s: %w", name, err)
+	}
+	return nil
+}
+
+// connectedToNetwork reports whether the container has an endpoint in the network, networkName may also be the network ID
+func (d *Docker) connectedToNetwork(networkName, containerID string) (bool, error) {
+	info, err := d.Client.ContainerInspect(d.Ctx, containerID)
+	if err != nil {
+		return false, fmt.Errorf("could not inspect container %s: %w", containerID, err)
+	}
+	if info.NetworkSettings == nil {
+		return false, nil
+	}
+	for name, endpoint := range info.NetworkSettings.Networks {
+		if name == networkName || (endpoint != nil && endpoint.NetworkID == networkName) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,91 @@
+package docker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+func attachedJSON(networks ...string) types.ContainerJSON {
+	settings := &types.NetworkSettings{Networks: map[string]*network.EndpointSettings{}}
+	for _, name := range networks {
+		settings.Networks[name] = &network.EndpointSettings{NetworkID: name + "-id"}
+	}
+	return types.ContainerJSON{NetworkSettings: settings}
+}
+
+func TestEnsureNetworkCreates(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("NetworkInspect", mock.Anything, "backend", mock.Anything).Return(network.Inspect{}, errdefs.NotFound(errors.New("not found")))
+	mockClient.On("NetworkCreate", mock.Anything, "backend", mock.MatchedBy(func(opts network.CreateOptions) bool {
+		return opts.Driver == "bridge" && opts.Labels["app"] == "shop" &&
+			opts.IPAM != nil && opts.IPAM.Config[0].Subnet == "172.28.0.0/16"
+	})).Return(network.CreateResponse{ID: "net123"}, nil)
+
+	id, err := d.EnsureNetwork("backend", docker.NetworkOptions{
+		Driver: "bridge",
+		Labels: map[string]string{"app": "shop"},
+		Subnet: "172.28.0.0/16",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "net123", id)
+}
+
+func TestEnsureNetworkExisting(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("NetworkInspect", mock.Anything, "backend", mock.Anything).Return(network.Inspect{ID: "net123", Driver: "bridge"}, nil)
+
+	id, err := d.EnsureNetwork("backend", docker.NetworkOptions{Driver: "bridge"})
+	require.NoError(t, err)
+	assert.Equal(t, "net123", id)
+	mockClient.AssertNotCalled(t, "NetworkCreate", mock.Anything, mock.Anything, mock.Anything)
+
+	_, err = d.EnsureNetwork("backend", docker.NetworkOptions{Driver: "overlay"})
+	assert.ErrorContains(t, err, "already exists with driver bridge")
+}
+
+func TestConnectNetwork(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("ContainerInspect", mock.Anything, "web").Return(attachedJSON("bridge"), nil)
+	mockClient.On("NetworkConnect", mock.Anything, "backend", "web", &network.EndpointSettings{Aliases: []string{"api"}}).Return(nil)
+
+	require.NoError(t, d.ConnectNetwork("backend", "web", "api"))
+	require.NoError(t, d.ConnectNetwork("bridge", "web"))
+	mockClient.AssertNumberOfCalls(t, "NetworkConnect", 1)
+}
+
+func TestDisconnectNetwork(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("ContainerInspect", mock.Anything, "web").Return(attachedJSON("backend"), nil)
+	mockClient.On("NetworkDisconnect", mock.Anything, "backend-id", "web", false).Return(nil)
+
+	require.NoError(t, d.DisconnectNetwork("backend-id", "web"))
+	require.NoError(t, d.DisconnectNetwork("frontend", "web"))
+	mockClient.AssertNumberOfCalls(t, "NetworkDisconnect", 1)
+}
+
+func TestRemoveNetworkIgnoresMissing(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("NetworkRemove", mock.Anything, "backend").Return(errdefs.NotFound(errors.New("not found")))
+
+	assert.NoError(t, d.RemoveNetwork("backend"))
+}
@@ -0,0 +1,345 @@
+package docker
+
+import (
+	"fmt"
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"sort"
+	"strings"
+	"time"
+)
+
+// anonymousVolumeLabel is set by docker on volumes created without a name
+const anonymousVolumeLabel = "com.docker.volume.anonymous"
+
+// PruneObject is a docker object type PrunePolicy can prune
+type PruneObject string
+
+const (
+	PruneContainers PruneObject = "containers"
+	PruneImages     PruneObject = "images"
+	PruneVolumes    PruneObject = "volumes"
+	PruneNetworks   PruneObject = "networks"
+	PruneBuildCache PruneObject = "buildcache"
+)
+
+// PrunePolicy selects which unused docker objects Prune removes, only the object types listed in Objects are touched
+type PrunePolicy struct {
+	Objects []PruneObject
+	// Until only prunes objects created more than Until ago, build cache entries use their last usage time
+	Until time.Duration
+	// IncludeLabels only prunes objects having all of these labels, entries are "key" or "key=value"
+	// Build cache has no labels, it is not pruned when IncludeLabels is set
+	IncludeLabels []string
+	// ExcludeLabels keeps objects having any of these labels, it doesn't apply to build cache since no entry can have a label
+	ExcludeLabels []string
+	// AllImages prunes unused tagged images as well, by default only dangling images are pruned
+	AllImages bool
+	// KeepLastImages keeps the N most recently created images of every repository even if they are unused
+	KeepLastImages int
+	// AllVolumes prunes unused named volumes as well, by default only anonymous volumes are pruned
+	AllVolumes bool
+	// DryRun reports what would be removed and the space it would free without removing anything
+	DryRun bool
+}
+
+// PruneResult holds the removed object IDs or names of a single object type
+type PruneResult struct {
+	Deleted        []string
+	SpaceReclaimed uint64
+}
+
+// PruneReport holds prune results per object type, Prune estimates image and volume space from disk usage data
+type PruneReport struct {
+	DryRun     bool
+	Containers PruneResult
+	Images     PruneResult
+	Volumes    PruneResult
+	Networks   PruneResult
+	BuildCache PruneResult
+}
+
+// SpaceReclaimed returns the space reclaimed by all object types
+func (r PruneReport) SpaceReclaimed() uint64 {
+	return r.Containers.SpaceReclaimed + r.Images.SpaceReclaimed + r.Volumes.SpaceReclaimed + r.Networks.SpaceReclaimed + r.BuildCache.SpaceReclaimed
+}
+
+// Prune removes unused docker objects selected by policy, on failure the report holds what was removed so far
+func (d *Docker) Prune(policy PrunePolicy) (PruneReport, error) {
+	report := PruneReport{DryRun: policy.DryRun}
+
+	var cutoff time.Time
+	if policy.Until > 0 {
+		cutoff = time.Now().Add(-policy.Until)
+	}
+
+	du, err := d.Client.DiskUsage(d.Ctx, types.DiskUsageOptions{})
+	if err != nil {
+		return report, fmt.Errorf("could not get docker disk usage: %w", err)
+	}
+
+	// Objects used only by containers pruned in the same run are free once those containers are gone
+	prunedContainers := make(map[string]bool)
+	if policy.prunes(PruneContainers) {
+		for _, c := range du.Containers {
+			if c.State != "created" && c.State != "exited" && c.State != "dead" {
+				continue
+			}
+			if !createdBefore(time.Unix(c.Created, 0), cutoff) || !policy.matchesLabels(c.Labels) {
+				continue
+			}
+			prunedContainers[c.ID] = true
+
+			if !policy.DryRun {
+				if err := d.Client.ContainerRemove(d.Ctx, c.ID, container.RemoveOptions{}); err != nil {
+					return report, fmt.Errorf("could not remove container %s: %w", c.ID, err)
+				}
+			}
+			report.Containers.Deleted = append(report.Containers.Deleted, c.ID)
+			report.Containers.SpaceReclaimed += uint64(max(c.SizeRw, 0))
+		}
+	}
+
+	usedImages := make(map[string]bool)
+	usedVolumes := make(map[string]bool)
+	usedNetworks := make(map[string]bool)
+	for _, c := range du.Containers {
+		if prunedContainers[c.ID] {
+			continue
+		}
+		usedImages[c.ImageID] = true
+		for _, m := range c.Mounts {
+			if m.Name != "" {
+				usedVolumes[m.Name] = true
+			}
+		}
+		if c.NetworkSettings != nil {
+			for name, endpoint := range c.NetworkSettings.Networks {
+				usedNetworks[name] = true
+				if endpoint != nil {
+					usedNetworks[endpoint.NetworkID] = true
+				}
+			}
+		}
+	}
+
+	if policy.prunes(PruneNetworks) {
+		networks, err := d.Client.NetworkList(d.Ctx, network.ListOptions{})
+		if err != nil {
+			return report, fmt.Errorf("could not list networks: %w", err)
+		}
+		for _, n := range networks {
+			if isPredefinedNetwork(n.Name) || usedNetworks[n.ID] || usedNetworks[n.Name] {
+				continue
+			}
+			if !createdBefore(n.Created, cutoff) || !policy.matchesLabels(n.Labels) {
+				continue
+			}
+
+			if !policy.DryRun {
+				if err := d.Client.NetworkRemove(d.Ctx, n.ID); err != nil {
+					return report, fmt.Errorf("could not remove network %s: %w", n.Name, err)
+				}
+			}
+			report.Networks.Deleted = append(report.Networks.Deleted, n.Name)
+		}
+	}
+
+	if policy.prunes(PruneImages) {
+		kept := policy.keptImages(du.Images)
+		for _, img := range du.Images {
+			if usedImages[img.ID] || kept[img.ID] {
+				continue
+			}
+			if !policy.AllImages && !isDanglingImage(img) {
+				continue
+			}
+			if !createdBefore(time.Unix(img.Created, 0), cutoff) || !policy.matchesLabels(img.Labels) {
+				continue
+			}
+
+			if !policy.DryRun {
+				if err := d.removeImage(img); err != nil {
+					return report, fmt.Errorf("could not remove image %s: %w", img.ID, err)
+				}
+			}
+			report.Images.Deleted = append(report.Images.Deleted, img.ID)
+			report.Images.SpaceReclaimed += uint64(max(img.Size-max(img.SharedSize, 0), 0))
+		}
+	}
+
+	if policy.prunes(PruneVolumes) {
+		for _, v := range du.Volumes {
+			if usedVolumes[v.Name] {
+				continue
+			}
+			if _, anonymous := v.Labels[anonymousVolumeLabel]; !anonymous && !policy.AllVolumes {
+				continue
+			}
+			// A volume whose age is unknown is never old enough, it is only kept when there is an age filter
+			createdAt, err := time.Parse(time.RFC3339, v.CreatedAt)
+			if err != nil && !cutoff.IsZero() {
+				continue
+			}
+			if !createdBefore(createdAt, cutoff) || !policy.matchesLabels(v.Labels) {
+				continue
+			}
+
+			if !policy.DryRun {
+				if err := d.Client.VolumeRemove(d.Ctx, v.Name, false); err != nil {
+					return report, fmt.Errorf("could not remove volume %s: %w", v.Name, err)
+				}
+			}
+			report.Volumes.Deleted = append(report.Volumes.Deleted, v.Name)
+			if v.UsageData != nil {
+				report.Volumes.SpaceReclaimed += uint64(max(v.UsageData.Size, 0))
+			}
+		}
+	}
+
+	// Build cache entries have no labels, so none of them can match the include labels
+	if policy.prunes(PruneBuildCache) && len(policy.IncludeLabels) == 0 {
+		if policy.DryRun {
+			for _, bc := range du.BuildCache {
+				lastUsed := bc.CreatedAt
+				if bc.LastUsedAt != nil {
+					lastUsed = *bc.LastUsedAt
+				}
+				if bc.InUse || !createdBefore(lastUsed, cutoff) {
+					continue
+				}
+				report.BuildCache.Deleted = append(report.BuildCache.Deleted, bc.ID)
+				report.BuildCache.SpaceReclaimed += uint64(max(bc.Size, 0))
+			}
+		} else {
+			cacheFilters := filters.NewArgs()
+			if policy.Until > 0 {
+				cacheFilters.Add("until", policy.Until.String())
+			}
+			bc, err := d.Client.BuildCachePrune(d.Ctx, types.BuildCachePruneOptions{
+				All:     true,
+				Filters: cacheFilters,
+			})
+			if err != nil {
+				return report, fmt.Errorf("could not prune build cache: %w", err)
+			}
+			report.BuildCache.Deleted = bc.CachesDeleted
+			report.BuildCache.SpaceReclaimed = bc.SpaceReclaimed
+		}
+	}
+
+	return report, nil
+}
+
+// removeImage untags img and removes it without forcing, so an image a container started using since the disk usage was read is kept
+// An image tagged in several repositories can't be removed by ID without force, so it is removed tag by tag
+func (d *Docker) removeImage(img *image.Summary) error {
+	var refs []string
+	for _, tag := range img.RepoTags {
+		if tag != "<none>:<none>" {
+			refs = append(refs, tag)
+		}
+	}
+	if len(refs) == 0 {
+		refs = []string{img.ID}
+	}
+	for _, ref := range refs {
+		if _, err := d.Client.ImageRemove(d.Ctx, ref, image.RemoveOptions{PruneChildren: true}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p PrunePolicy) prunes(object PruneObject) bool {
+	for _, o := range p.Objects {
+		if o == object {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesLabels reports whether labels contain every include label and none of the exclude labels
+func (p PrunePolicy) matchesLabels(labels map[string]string) bool {
+	for _, l := range p.IncludeLabels {
+		if !hasLabel(labels, l) {
+			return false
+		}
+	}
+	for _, l := range p.ExcludeLabels {
+		if hasLabel(labels, l) {
+			return false
+		}
+	}
+	return true
+}
+
+// keptImages returns the IDs of the KeepLastImages newest images of every repository
+func (p PrunePolicy) keptImages(images []*image.Summary) map[string]bool {
+	kept := make(map[string]bool)
+	if p.KeepLastImages <= 0 {
+		return kept
+	}
+
+	byRepository := make(map[string][]*image.Summary)
+	for _, img := range images {
+		repositories := make(map[string]bool)
+		for _, tag := range img.RepoTags {
+			named, err := reference.ParseNormalizedNamed(tag)
+			if err != nil || tag == "<none>:<none>" {
+				continue
+			}
+			repositories[named.Name()] = true
+		}
+		for repo := range repositories {
+			byRepository[repo] = append(byRepository[repo], img)
+		}
+	}
+
+	for _, repoImages := range byRepository {
+		sort.SliceStable(repoImages, func(i, j int) bool {
+			return repoImages[i].Created > repoImages[j].Created
+		})
+		for i := 0; i < len(repoImages) && i < p.KeepLastImages; i++ {
+			kept[repoImages[i].ID] = true
+		}
+	}
+
+	return kept
+}
+
+func hasLabel(labels map[string]string, label string) bool {
+	key, value, hasValue := strings.Cut(label, "=")
+	v, ok := labels[key]
+	if !ok {
+		return false
+	}
+	return !hasValue || v == value
+}
+
+// createdBefore reports whether t is before cutoff, a zero cutoff matches everything
+func createdBefore(t, cutoff time.Time) bool {
+	return cutoff.IsZero() || t.Before(cutoff)
+}
+
+func isDanglingImage(img *image.Summary) bool {
+	for _, tag := range img.RepoTags {
+		if tag != "<none>:<none>" {
+			return false
+		}
+	}
+	return true
+}
+
+func isPredefinedNetwork(name string) bool {
+	switch name {
+	case "bridge", "host", "none", "ingress", "docker_gwbridge":
+		return true
+	}
+	return false
+}
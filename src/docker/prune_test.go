@@ -0,0 +1,147 @@
+package docker_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+func testDiskUsage() types.DiskUsage {
+	old := time.Now().Add(-72 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+
+	return types.DiskUsage{
+		Containers: []*types.Container{
+			{ID: "running", State: "running", ImageID: "app-v3", Created: old.Unix(), Mounts: []types.MountPoint{{Name: "data"}}},
+			{ID: "exited-old", State: "exited", ImageID: "app-v1", Created: old.Unix(), SizeRw: 10},
+			{ID: "exited-recent", State: "exited", ImageID: "app-v2", Created: recent.Unix(), SizeRw: 20},
+			{ID: "exited-keep", State: "exited", ImageID: "app-v2", Created: old.Unix(), SizeRw: 30, Labels: map[string]string{"keep": "true"}},
+		},
+		Images: []*image.Summary{
+			{ID: "app-v1", RepoTags: []string{"example/app:v1"}, Created: old.Add(-3 * time.Hour).Unix(), Size: 100},
+			{ID: "app-v2", RepoTags: []string{"example/app:v2"}, Created: old.Add(-2 * time.Hour).Unix(), Size: 200},
+			{ID: "app-v3", RepoTags: []string{"example/app:v3"}, Created: old.Add(-time.Hour).Unix(), Size: 300},
+			{ID: "dangling", RepoTags: []string{"<none>:<none>"}, Created: old.Unix(), Size: 400},
+		},
+		Volumes: []*volume.Volume{
+			{Name: "data", CreatedAt: old.Format(time.RFC3339), UsageData: &volume.UsageData{Size: 1000}},
+			{Name: "named-unused", CreatedAt: old.Format(time.RFC3339), UsageData: &volume.UsageData{Size: 2000}},
+			{Name: "anonymous", CreatedAt: old.Format(time.RFC3339), Labels: map[string]string{"com.docker.volume.anonymous": ""}, UsageData: &volume.UsageData{Size: 3000}},
+		},
+		BuildCache: []*types.BuildCache{
+			{ID: "cache-old", CreatedAt: old, LastUsedAt: &old, Size: 50},
+			{ID: "cache-in-use", CreatedAt: old, InUse: true, Size: 60},
+		},
+	}
+}
+
+func TestPruneDryRun(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("DiskUsage", mock.Anything, mock.Anything).Return(testDiskUsage(), nil)
+	mockClient.On("NetworkList", mock.Anything, mock.Anything).Return([]network.Summary{
+		{ID: "n1", Name: "bridge"},
+		{ID: "n2", Name: "unused"},
+	}, nil)
+
+	report, err := d.Prune(docker.PrunePolicy{
+		Objects:       []docker.PruneObject{docker.PruneContainers, docker.PruneImages, docker.PruneVolumes, docker.PruneNetworks, docker.PruneBuildCache},
+		Until:         24 * time.Hour,
+		ExcludeLabels: []string{"keep=true"},
+		DryRun:        true,
+	})
+	require.NoError(t, err)
+
+	assert.True(t, report.DryRun)
+	assert.Equal(t, []string{"exited-old"}, report.Containers.Deleted)
+	assert.Equal(t, []string{"dangling"}, report.Images.Deleted)
+	assert.Equal(t, []string{"anonymous"}, report.Volumes.Deleted)
+	assert.Equal(t, []string{"unused"}, report.Networks.Deleted)
+	assert.Equal(t, []string{"cache-old"}, report.BuildCache.Deleted)
+	assert.Equal(t, uint64(10+400+3000+50), report.SpaceReclaimed())
+
+	mockClient.AssertNotCalled(t, "ContainerRemove", mock.Anything, mock.Anything, mock.Anything)
+	mockClient.AssertNotCalled(t, "ImageRemove", mock.Anything, mock.Anything, mock.Anything)
+	mockClient.AssertNotCalled(t, "VolumeRemove", mock.Anything, mock.Anything, mock.Anything)
+	mockClient.AssertExpectations(t)
+}
+
+func TestPruneKeepsLastImagesPerRepository(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("DiskUsage", mock.Anything, mock.Anything).Return(testDiskUsage(), nil)
+	mockClient.On("ContainerRemove", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	notForced := mock.MatchedBy(func(opts image.RemoveOptions) bool { return !opts.Force && opts.PruneChildren })
+	mockClient.On("ImageRemove", mock.Anything, "example/app:v1", notForced).Return([]image.DeleteResponse{{Untagged: "example/app:v1"}, {Deleted: "app-v1"}}, nil)
+	mockClient.On("ImageRemove", mock.Anything, "dangling", notForced).Return([]image.DeleteResponse{{Deleted: "dangling"}}, nil)
+
+	report, err := d.Prune(docker.PrunePolicy{
+		Objects:        []docker.PruneObject{docker.PruneContainers, docker.PruneImages},
+		AllImages:      true,
+		KeepLastImages: 2,
+	})
+	require.NoError(t, err)
+
+	// app-v2 and app-v3 are the newest two images of example/app, app-v1 became unused once its container was pruned
+	assert.ElementsMatch(t, []string{"exited-old", "exited-recent", "exited-keep"}, report.Containers.Deleted)
+	assert.ElementsMatch(t, []string{"app-v1", "dangling"}, report.Images.Deleted)
+	assert.Empty(t, report.Volumes.Deleted)
+
+	mockClient.AssertNotCalled(t, "NetworkList", mock.Anything, mock.Anything)
+	mockClient.AssertNotCalled(t, "VolumeRemove", mock.Anything, mock.Anything, mock.Anything)
+	mockClient.AssertExpectations(t)
+}
+
+func TestPruneSkipsUnknownAgesAndLabelledBuildCache(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	du := testDiskUsage()
+	du.Volumes = append(du.Volumes, &volume.Volume{Name: "no-date", Labels: map[string]string{"com.docker.volume.anonymous": "", "env": "stage"}})
+	du.Volumes[2].Labels["env"] = "stage"
+	mockClient.On("DiskUsage", mock.Anything, mock.Anything).Return(du, nil)
+
+	report, err := d.Prune(docker.PrunePolicy{
+		Objects:       []docker.PruneObject{docker.PruneVolumes, docker.PruneBuildCache},
+		Until:         24 * time.Hour,
+		IncludeLabels: []string{"env=stage"},
+		DryRun:        true,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"anonymous"}, report.Volumes.Deleted)
+	assert.Empty(t, report.BuildCache.Deleted)
+
+	_, err = d.Prune(docker.PrunePolicy{
+		Objects:       []docker.PruneObject{docker.PruneBuildCache},
+		IncludeLabels: []string{"env=stage"},
+	})
+	require.NoError(t, err)
+	mockClient.AssertNotCalled(t, "BuildCachePrune", mock.Anything, mock.Anything)
+	mockClient.AssertExpectations(t)
+}
+
+func TestPruneVolumesOfUnknownAgeWithoutAgeFilter(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	du := testDiskUsage()
+	du.Volumes = append(du.Volumes, &volume.Volume{Name: "no-date", Labels: map[string]string{"com.docker.volume.anonymous": ""}})
+	mockClient.On("DiskUsage", mock.Anything, mock.Anything).Return(du, nil)
+
+	report, err := d.Prune(docker.PrunePolicy{Objects: []docker.PruneObject{docker.PruneVolumes}, DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"anonymous", "no-date"}, report.Volumes.Deleted)
+}
@@ -0,0 +1,163 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/onurcevik/deploy-utilities/src/utils"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sshDaemonHost is a placeholder host for clients tunneled over ssh, the dialer ignores it and the daemon never sees it
+const sshDaemonHost = "http://docker.example.com"
+
+// WithSSH connects the Docker client to the daemon of a remote host through ssh using `docker system dial-stdio`
+// The remote user needs access to the docker socket, no TCP port or TLS certificates are needed on the host
+func WithSSH(sshCtx utils.SSHContext) ClientOption {
+	return func(c *clientConfig) error {
+		if sshCtx.RemoteHost == "" {
+			return fmt.Errorf("ssh context has no remote host")
+		}
+		c.host = sshDaemonHost
+		c.dialContext = sshDialer(sshCtx, "")
+		return nil
+	}
+}
+
+// parseSSHHost converts an ssh://[user@]host[:port] docker host into an SSHContext and port
+func parseSSHHost(host string) (utils.SSHContext, string, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return utils.SSHContext{}, "", fmt.Errorf("invalid ssh host %s: %w", host, err)
+	}
+	if u.Scheme != "ssh" || u.Hostname() == "" {
+		return utils.SSHContext{}, "", fmt.Errorf("invalid ssh host %s, expected ssh://[user@]host[:port]", host)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return utils.SSHContext{}, "", fmt.Errorf("invalid ssh host %s, paths are not supported", host)
+	}
+
+	return utils.SSHContext{
+		RemoteUser: u.User.Username(),
+		RemoteHost: u.Hostname(),
+	}, u.Port(), nil
+}
+
+// sshDialer returns a dialer that starts a new ssh process for every connection the Docker client opens
+func sshDialer(sshCtx utils.SSHContext, port string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		args := append([]string{"-o", "StrictHostKeyChecking=no", "-o", "BatchMode=yes"}, sshCtx.SSHOptions()...)
+		if port != "" {
+			args = append(args, "-p", port)
+		}
+		args = append(args, "--", sshCtx.Destination(), "docker", "system", "dial-stdio")
+
+		// The process outlives the dial context, it is stopped when the connection is closed
+		return newCommandConn(exec.Command("ssh", args...), sshCtx.Destination())
+	}
+}
+
+// commandConn is a net.Conn over the stdin and stdout of a command
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr lockedBuffer
+	remote string
+
+	closeOnce sync.Once
+}
+
+// lockedBuffer collects stderr of the command, it is written by the exec package while Read may look at it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func newCommandConn(cmd *exec.Cmd, remote string) (net.Conn, error) {
+	c := &commandConn{cmd: cmd, remote: remote}
+
+	var err error
+	c.stdin, err = cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	c.stdout, err = cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = &c.stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("could not start ssh to %s: %w", remote, err)
+	}
+
+	return c, nil
+}
+
+func (c *commandConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err == io.EOF {
+		if stderr := strings.TrimSpace(c.stderr.String()); stderr != "" {
+			return n, fmt.Errorf("ssh connection to %s closed: %s", c.remote, stderr)
+		}
+	}
+	return n, err
+}
+
+func (c *commandConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+// CloseWrite closes stdin so hijacked connections such as exec attach can signal the end of input
+func (c *commandConn) CloseWrite() error {
+	return c.stdin.Close()
+}
+
+func (c *commandConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.stdin.Close()
+		if c.cmd.Process != nil {
+			_ = c.cmd.Process.Kill()
+		}
+		// Wait closes stdout and reaps the process, the kill above makes it exit with an error we don't care about
+		_ = c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr {
+	return commandAddr("local")
+}
+
+func (c *commandConn) RemoteAddr() net.Addr {
+	return commandAddr(c.remote)
+}
+
+// Deadlines are not supported by pipes, request timeouts are handled by the http client and contexts instead
+func (c *commandConn) SetDeadline(time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(time.Time) error { return nil }
+
+type commandAddr string
+
+func (a commandAddr) Network() string { return "ssh" }
+func (a commandAddr) String() string  { return string(a) }
@@ -0,0 +1,106 @@
+package docker_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+	"github.com/onurcevik/deploy-utilities/src/utils"
+)
+
+// TestMain lets the test binary act as a fake ssh command when it is started through the ssh symlink created by fakeSSH
+func TestMain(m *testing.M) {
+	if filepath.Base(os.Args[0]) == "ssh" {
+		os.Exit(runFakeSSH())
+	}
+	os.Exit(m.Run())
+}
+
+// runFakeSSH records its arguments and proxies stdin and stdout to FAKE_SSH_TARGET like docker system dial-stdio does
+func runFakeSSH() int {
+	if err := os.WriteFile(os.Getenv("FAKE_SSH_ARGS_FILE"), []byte(strings.Join(os.Args[1:], " ")), 0600); err != nil {
+		return 1
+	}
+	conn, err := net.Dial("tcp", os.Getenv("FAKE_SSH_TARGET"))
+	if err != nil {
+		return 1
+	}
+	go func() { _, _ = io.Copy(conn, os.Stdin) }()
+	_, _ = io.Copy(os.Stdout, conn)
+	return 0
+}
+
+// fakeSSH puts a fake ssh command connected to target first in PATH and returns the file its arguments are written to
+func fakeSSH(t *testing.T, target string) string {
+	dir := t.TempDir()
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	require.NoError(t, os.Symlink(executable, filepath.Join(dir, "ssh")))
+
+	argsFile := filepath.Join(dir, "args")
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_SSH_TARGET", target)
+	t.Setenv("FAKE_SSH_ARGS_FILE", argsFile)
+
+	return argsFile
+}
+
+func TestNewClientWithSSH(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(versionHandler(&paths))
+	defer server.Close()
+	argsFile := fakeSSH(t, server.Listener.Addr().String())
+
+	cli, err := docker.NewClient(
+		docker.WithSSH(utils.SSHContext{
+			RemoteUser:   "ec2-user",
+			RemoteHost:   "10.0.1.15",
+			IdentityFile: "/keys/deploy.pem",
+			JumpHost:     "ubuntu@bastion.example.com",
+		}),
+		docker.WithAPIVersion("1.45"),
+	)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	version, err := cli.ServerVersion(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "27.0.2", version.Version)
+
+	args, err := os.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "-i /keys/deploy.pem -J ubuntu@bastion.example.com")
+	assert.True(t, strings.HasSuffix(string(args), "-- ec2-user@10.0.1.15 docker system dial-stdio"))
+}
+
+func TestNewClientWithSSHHost(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(versionHandler(&paths))
+	defer server.Close()
+	argsFile := fakeSSH(t, server.Listener.Addr().String())
+
+	cli, err := docker.NewClient(docker.WithHost("ssh://deploy@docker-1.internal:2222"), docker.WithAPIVersion("1.45"))
+	require.NoError(t, err)
+	defer cli.Close()
+
+	_, err = cli.ServerVersion(context.Background())
+	require.NoError(t, err)
+
+	args, err := os.ReadFile(argsFile)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "-p 2222 -- deploy@docker-1.internal docker system dial-stdio")
+}
+
+func TestNewClientWithInvalidSSHHost(t *testing.T) {
+	_, err := docker.NewClient(docker.WithHost("ssh://deploy@docker-1.internal/var/run/docker.sock"))
+	assert.ErrorContains(t, err, "paths are not supported")
+}
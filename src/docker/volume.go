@@ -0,0 +1,67 @@
+package docker
+
+import (
+	"fmt"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+)
+
+// VolumeOptions configures a volume created by EnsureVolume
+type VolumeOptions struct {
+	// Driver defaults to local on the daemon side
+	Driver     string
+	DriverOpts map[string]string
+	Labels     map[string]string
+}
+
+// EnsureVolume creates the volume if it doesn't exist and returns it
+// An existing volume is left untouched unless it uses a different driver than opts.Driver, which is an error
+func (d *Docker) EnsureVolume(name string, opts VolumeOptions) (volume.Volume, error) {
+	existing, err := d.Client.VolumeInspect(d.Ctx, name)
+	if err == nil {
+		if opts.Driver != "" && existing.Driver != "" && existing.Driver != opts.Driver {
+			return volume.Volume{}, fmt.Errorf("volume %s already exists with driver %s instead of %s", name, existing.Driver, opts.Driver)
+		}
+		return existing, nil
+	}
+	if !errdefs.IsNotFound(err) {
+		return volume.Volume{}, fmt.Errorf("could not inspect volume %s: %w", name, err)
+	}
+
+	created, err := d.Client.VolumeCreate(d.Ctx, volume.CreateOptions{
+		Name:       name,
+		Driver:     opts.Driver,
+		DriverOpts: opts.DriverOpts,
+		Labels:     opts.Labels,
+	})
+	if err != nil {
+		return volume.Volume{}, fmt.Errorf("could not create volume %s: %w", name, err)
+	}
+
+	return created, nil
+}
+
+// ListVolumes returns the volumes matching all given labels, labels are "key" or "key=value", no labels lists every volume
+func (d *Docker) ListVolumes(labels ...string) ([]*volume.Volume, error) {
+	args := filters.NewArgs()
+	for _, label := range labels {
+		args.Add("label", label)
+	}
+
+	resp, err := d.Client.VolumeList(d.Ctx, volume.ListOptions{Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("could not list volumes: %w", err)
+	}
+	return resp.Volumes, nil
+}
+
+// RemoveVolume removes a volume, a volume that doesn't exist is not an error
+// force removes the volume even if a container still references it
+func (d *Docker) RemoveVolume(name string, force bool) error {
+	err := d.Client.VolumeRemove(d.Ctx, name, force)
+	if err != nil && !errdefs.IsNotFound(err) {
+		return fmt.Errorf("could not remove volume %s: %w", name, err)
+	}
+	return nil
+}
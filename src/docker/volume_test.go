@@ -0,0 +1,63 @@
+package docker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/errdefs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/onurcevik/deploy-utilities/src/docker"
+)
+
+func TestEnsureVolume(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("VolumeInspect", mock.Anything, "pgdata").Return(volume.Volume{}, errdefs.NotFound(errors.New("not found"))).Once()
+	mockClient.On("VolumeCreate", mock.Anything, volume.CreateOptions{
+		Name:   "pgdata",
+		Driver: "local",
+		Labels: map[string]string{"app": "shop"},
+	}).Return(volume.Volume{Name: "pgdata", Driver: "local"}, nil).Once()
+	mockClient.On("VolumeInspect", mock.Anything, "pgdata").Return(volume.Volume{Name: "pgdata", Driver: "local"}, nil)
+
+	opts := docker.VolumeOptions{Driver: "local", Labels: map[string]string{"app": "shop"}}
+	created, err := d.EnsureVolume("pgdata", opts)
+	require.NoError(t, err)
+	assert.Equal(t, "pgdata", created.Name)
+
+	// The second call finds the volume and doesn't create it again
+	_, err = d.EnsureVolume("pgdata", opts)
+	require.NoError(t, err)
+	mockClient.AssertNumberOfCalls(t, "VolumeCreate", 1)
+}
+
+func TestListVolumes(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("VolumeList", mock.Anything, mock.MatchedBy(func(opts volume.ListOptions) bool {
+		return opts.Filters.ExactMatch("label", "app=shop") && opts.Filters.ExactMatch("label", "backup")
+	})).Return(volume.ListResponse{Volumes: []*volume.Volume{{Name: "pgdata"}}}, nil)
+
+	volumes, err := d.ListVolumes("app=shop", "backup")
+	require.NoError(t, err)
+	require.Len(t, volumes, 1)
+	assert.Equal(t, "pgdata", volumes[0].Name)
+}
+
+func TestRemoveVolume(t *testing.T) {
+	mockClient := new(MockDockerClient)
+	d := docker.Docker{Client: mockClient, Ctx: context.Background()}
+
+	mockClient.On("VolumeRemove", mock.Anything, "pgdata", true).Return(errdefs.NotFound(errors.New("not found"))).Once()
+	mockClient.On("VolumeRemove", mock.Anything, "pgdata", false).Return(errdefs.Conflict(errors.New("volume is in use"))).Once()
+
+	assert.NoError(t, d.RemoveVolume("pgdata", true))
+	assert.ErrorContains(t, d.RemoveVolume("pgdata", false), "volume is in use")
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/client"
+	"github.com/onurcevik/deploy-utilities/src/docker"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -60,6 +64,37 @@ func CollectDockerContainerMetrics(interval time.Duration) {
 	}
 }
 
+// CollectDockerEventMetrics counts OOM kills, restarts and exits per container from the docker events stream until ctx is cancelled
+// Containers restarted by their restart policy only report die and start, so a start following a die is counted as a restart
+func CollectDockerEventMetrics(ctx context.Context, d *docker.Docker) error {
+	exited := map[string]bool{}
+	return d.WatchEvents(ctx, docker.EventWatchOptions{
+		Filters: filters.NewArgs(filters.Arg("type", string(events.ContainerEventType))),
+	}, docker.EventHandlers{
+		OnContainerOOM: func(e docker.ContainerEvent) {
+			containerOOMKills.WithLabelValues(e.Name).Inc()
+		},
+		OnContainerDie: func(e docker.ContainerEvent) {
+			exited[e.ID] = true
+			containerExits.WithLabelValues(e.Name, strconv.Itoa(e.ExitCode)).Inc()
+		},
+		OnContainerStart: func(e docker.ContainerEvent) {
+			if exited[e.ID] {
+				delete(exited, e.ID)
+				containerRestarts.WithLabelValues(e.Name).Inc()
+			}
+		},
+		OnEvent: func(msg events.Message) {
+			if msg.Action == events.ActionDestroy {
+				delete(exited, msg.Actor.ID)
+			}
+		},
+		OnReconnect: func(err error) {
+			log.Printf("docker events stream broke, reconnecting: %s", err)
+		},
+	})
+}
+
 // StartMetricsServer starts an HTTP server to expose monitoring to Prometheus
 func StartMetricsServer() {
 	http.Handle("/metrics", promhttp.Handler())
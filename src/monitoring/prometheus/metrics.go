@@ -20,11 +20,38 @@ var (
 		},
 		[]string{"container_id"},
 	)
+	containerOOMKills = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "docker",
+			Name:      "container_oom_kills_total",
+			Help:      "Number of times containers were killed for running out of memory",
+		},
+		[]string{"container_name"},
+	)
+	containerRestarts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "docker",
+			Name:      "container_restarts_total",
+			Help:      "Number of times containers were restarted",
+		},
+		[]string{"container_name"},
+	)
+	containerExits = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "docker",
+			Name:      "container_exits_total",
+			Help:      "Number of times containers exited, by exit code",
+		},
+		[]string{"container_name", "exit_code"},
+	)
 )
 
 // RegisterPrometheusMetrics registers Prometheus metrics for monitoring
 func RegisterPrometheusMetrics() {
 	prometheus.MustRegister(containerCPUUsage)
 	prometheus.MustRegister(containerMemUsage)
+	prometheus.MustRegister(containerOOMKills)
+	prometheus.MustRegister(containerRestarts)
+	prometheus.MustRegister(containerExits)
 	// Register additional metrics here if needed
 }
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// ErrNonZeroExit is wrapped by the error Executor.Exec returns when the command ran but exited with a non-zero code
+var ErrNonZeroExit = errors.New("command exited with non-zero code")
+
+// ExecResult is the output of a remote command, ExitCode is -1 when the command could not be run at all
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// Executor runs a shell command on a remote host, deploy steps take an Executor so the transport can be chosen per host
+// Exec returns an error when the command could not be run or exited with a non-zero code, the result holds whatever output was collected either way
+// Errors never contain the command since it may carry credentials such as presigned URLs
+type Executor interface {
+	Exec(cmd string) (ExecResult, error)
+}
+
+// SSHExecutor runs commands over ssh with the identity file and jump host of its SSHContext
+// Unlike RemoteExec it has no default identity file, ssh picks its own default keys when IdentityFile is empty
+type SSHExecutor struct {
+	SSHContext
+}
+
+// NewSSHExecutor returns an Executor for the ssh destination
+func NewSSHExecutor(sshCtx SSHContext) *SSHExecutor {
+	return &SSHExecutor{SSHContext: sshCtx}
+}
+
+// Exec runs cmd on the remote host and collects its stdout, stderr and exit code
+// ssh itself exits with 255 when the connection fails, which is reported like any other exit code
+func (e *SSHExecutor) Exec(cmd string) (ExecResult, error) {
+	args := append(e.SSHOptions(), "-o", "StrictHostKeyChecking=no", e.Destination(), cmd)
+
+	var stdout, stderr bytes.Buffer
+	command := exec.Command("ssh", args...)
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+
+	err := command.Run()
+	result := ExecResult{Stdout: stdout.String(), Stderr: stderr.String()}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return result, nil
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+		return result, fmt.Errorf("command on %s: %w (exit code %d)", e.RemoteHost, ErrNonZeroExit, result.ExitCode)
+	default:
+		result.ExitCode = -1
+		return result, fmt.Errorf("could not run ssh to %s: %w", e.RemoteHost, err)
+	}
+}
@@ -7,11 +7,16 @@ import (
 )
 
 func RemoteExec(sshCtx SSHContext, cmd string) error {
+	var jump string
+	if sshCtx.JumpHost != "" {
+		jump = fmt.Sprintf("-J %s ", sshCtx.JumpHost)
+	}
+
 	var command string
 	if sshCtx.IdentityFile == "" {
-		command = fmt.Sprintf("ssh -i ~/DevOps/.keys/test-stage-key.pem -o StrictHostKeyChecking=no %s@%s \"%s\"", sshCtx.RemoteUser, sshCtx.RemoteHost, cmd)
+		command = fmt.Sprintf("ssh -i ~/DevOps/.keys/test-stage-key.pem %s-o StrictHostKeyChecking=no %s@%s \"%s\"", jump, sshCtx.RemoteUser, sshCtx.RemoteHost, cmd)
 	} else {
-		command = fmt.Sprintf("ssh -i %s -o StrictHostKeyChecking=no %s@%s \"%s\"", sshCtx.IdentityFile, sshCtx.RemoteUser, sshCtx.RemoteHost, cmd)
+		command = fmt.Sprintf("ssh -i %s %s-o StrictHostKeyChecking=no %s@%s \"%s\"", sshCtx.IdentityFile, jump, sshCtx.RemoteUser, sshCtx.RemoteHost, cmd)
 	}
 
 	err := exec.Command("sh", "-c", command).Run()
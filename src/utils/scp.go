@@ -7,11 +7,43 @@ import (
 )
 
 func SCP(sshCtx SSHContext, fromPath, toPath string, errorIgnore bool) error {
+	var jump string
+	if sshCtx.JumpHost != "" {
+		jump = fmt.Sprintf("-J %s ", sshCtx.JumpHost)
+	}
+
+	var command string
+	if sshCtx.IdentityFile == "" {
+		command = fmt.Sprintf("scp %s-r %s %s@%s:%s", jump, fromPath, sshCtx.RemoteUser, sshCtx.RemoteHost, toPath)
+	} else {
+		command = fmt.Sprintf("scp -i %s %s-r %s %s@%s:%s", sshCtx.IdentityFile, jump, fromPath, sshCtx.RemoteUser, sshCtx.RemoteHost, toPath)
+	}
+
+	err := exec.Command("sh", "-c", command).Run()
+	if err != nil {
+		log.Printf("file transportation; from=%s to=%s\nresult: %v", fromPath, toPath, err)
+		if !errorIgnore {
+			return fmt.Errorf("file transportation failed with error: %v", err)
+		}
+	} else {
+		log.Printf("file transportation; from=%s to=%s\nresult: success", fromPath, toPath)
+	}
+
+	return nil
+}
+
+// SCPFrom copies fromPath on the remote host to toPath on the local machine
+func SCPFrom(sshCtx SSHContext, fromPath, toPath string, errorIgnore bool) error {
+	var jump string
+	if sshCtx.JumpHost != "" {
+		jump = fmt.Sprintf("-J %s ", sshCtx.JumpHost)
+	}
+
 	var command string
 	if sshCtx.IdentityFile == "" {
-		command = fmt.Sprintf("scp -r %s %s@%s:%s", fromPath, sshCtx.RemoteUser, sshCtx.RemoteHost, toPath)
+		command = fmt.Sprintf("scp %s-r %s@%s:%s %s", jump, sshCtx.RemoteUser, sshCtx.RemoteHost, fromPath, toPath)
 	} else {
-		command = fmt.Sprintf("scp -i %s -r %s %s@%s:%s", sshCtx.IdentityFile, fromPath, sshCtx.RemoteUser, sshCtx.RemoteHost, toPath)
+		command = fmt.Sprintf("scp -i %s %s-r %s@%s:%s %s", sshCtx.IdentityFile, jump, sshCtx.RemoteUser, sshCtx.RemoteHost, fromPath, toPath)
 	}
 
 	err := exec.Command("sh", "-c", command).Run()
@@ -1,9 +1,11 @@
 package utils
 
+// SSHContext holds the ssh destination, JumpHost is passed to ssh -J and may hold a comma separated list of [user@]host[:port]
 type SSHContext struct {
 	RemoteUser   string
 	RemoteHost   string
 	IdentityFile string
+	JumpHost     string
 }
 
 func NewSSHContext(remoteUser, remoteHost, identityFile string) *SSHContext {
@@ -13,3 +15,23 @@ func NewSSHContext(remoteUser, remoteHost, identityFile string) *SSHContext {
 		IdentityFile: identityFile,
 	}
 }
+
+// Destination returns the user@host ssh destination
+func (s SSHContext) Destination() string {
+	if s.RemoteUser == "" {
+		return s.RemoteHost
+	}
+	return s.RemoteUser + "@" + s.RemoteHost
+}
+
+// SSHOptions returns the identity file and jump host options for the ssh command
+func (s SSHContext) SSHOptions() []string {
+	var opts []string
+	if s.IdentityFile != "" {
+		opts = append(opts, "-i", s.IdentityFile)
+	}
+	if s.JumpHost != "" {
+		opts = append(opts, "-J", s.JumpHost)
+	}
+	return opts
+}
@@ -1,9 +1,11 @@
 package utils_test
 
 import (
+	"fmt"
 	"github.com/onurcevik/deploy-utilities/src/utils"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -72,3 +74,78 @@ func TestSCP(t *testing.T) {
 	err := utils.SCP(sshCtx, "", "", true)
 	assert.NoError(t, err)
 }
+
+// fakeCommand puts a script named name first on PATH, it records its arguments in the returned file and runs body
+func fakeCommand(t *testing.T, name, body string) string {
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, name+".args")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %s\n%s\n", argsFile, body)
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return argsFile
+}
+
+// TestSCPFrom tests the SCPFrom function
+func TestSCPFrom(t *testing.T) {
+	argsFile := fakeCommand(t, "scp", "exit 0")
+	sshCtx := utils.SSHContext{
+		RemoteUser:   "testuser",
+		RemoteHost:   "testhost",
+		JumpHost:     "bastion",
+		IdentityFile: "key.pem",
+	}
+
+	err := utils.SCPFrom(sshCtx, "/var/backups/db.tar.gz", "/tmp/db.tar.gz", false)
+	assert.NoError(t, err)
+
+	args, err := os.ReadFile(argsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "-i key.pem -J bastion -r testuser@testhost:/var/backups/db.tar.gz /tmp/db.tar.gz\n", string(args))
+}
+
+// TestSCPFromFailure tests that SCPFrom reports a failed copy unless errors are ignored
+func TestSCPFromFailure(t *testing.T) {
+	fakeCommand(t, "scp", "exit 1")
+	sshCtx := utils.SSHContext{RemoteUser: "testuser", RemoteHost: "testhost"}
+
+	assert.Error(t, utils.SCPFrom(sshCtx, "/var/backups/db.tar.gz", "/tmp/db.tar.gz", false))
+	assert.NoError(t, utils.SCPFrom(sshCtx, "/var/backups/db.tar.gz", "/tmp/db.tar.gz", true))
+}
+
+// TestSSHExecutorExec tests that SSHExecutor reports the exit code and output of the remote command
+func TestSSHExecutorExec(t *testing.T) {
+	argsFile := fakeCommand(t, "ssh", "echo out; echo err >&2; exit 3")
+	executor := utils.NewSSHExecutor(utils.SSHContext{RemoteUser: "deploy", RemoteHost: "web-1", IdentityFile: "key.pem"})
+
+	result, err := executor.Exec("docker ps")
+	assert.ErrorIs(t, err, utils.ErrNonZeroExit)
+	assert.NotContains(t, err.Error(), "docker ps")
+	assert.Equal(t, utils.ExecResult{Stdout: "out\n", Stderr: "err\n", ExitCode: 3}, result)
+
+	args, err := os.ReadFile(argsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "-i key.pem -o StrictHostKeyChecking=no deploy@web-1 docker ps\n", string(args))
+}
+
+// TestSSHExecutorExecSuccess tests that a zero exit code is not an error
+func TestSSHExecutorExecSuccess(t *testing.T) {
+	fakeCommand(t, "ssh", "echo up")
+	executor := utils.NewSSHExecutor(utils.SSHContext{RemoteHost: "web-1"})
+
+	result, err := executor.Exec("uptime")
+	assert.NoError(t, err)
+	assert.Equal(t, utils.ExecResult{Stdout: "up\n"}, result)
+}
+
+// TestSSHExecutorExecWithoutSSH tests that a missing ssh binary is reported with the exit code -1
+func TestSSHExecutorExecWithoutSSH(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	executor := utils.NewSSHExecutor(utils.SSHContext{RemoteHost: "web-1"})
+
+	result, err := executor.Exec("uptime")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, utils.ErrNonZeroExit)
+	assert.Equal(t, -1, result.ExitCode)
+}